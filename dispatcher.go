@@ -10,6 +10,7 @@ import (
 
 type Dispatcher struct {
 	handlers map[string]func(context.Context, json.RawMessage) Resp
+	hooks    []func(context.Context, string)
 }
 
 func NewDispatcher() Dispatcher {
@@ -74,9 +75,20 @@ func (d *Dispatcher) Read(ctx context.Context, r io.Reader) Resp {
 		})
 	}
 	ctx = patchLogger(ctx, envelope.Data.Type)
+	for _, hook := range d.hooks {
+		hook(ctx, envelope.Data.Type)
+	}
 	return h(ctx, envelope.Data.Attributes)
 }
 
+// Call the given function for every request with a registered type before handling it.
+//
+// The function accepts the request context and the request type.
+// Useful for collecting metrics.
+func (d *Dispatcher) OnDispatch(f func(context.Context, string)) {
+	d.hooks = append(d.hooks, f)
+}
+
 // If the context has a logger, add the request-type into the log extras.
 func patchLogger(ctx context.Context, t string) context.Context {
 	raw := ctx.Value(ctxKey[*slog.Logger]{})
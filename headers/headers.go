@@ -589,4 +589,89 @@ const (
 	//
 	// https://developer.mozilla.org/en-US/docs/Web/HTTP/Headers/Warning
 	Warning Header = "Warning"
+
+	// A unique key that lets the server recognize retries of the same non-idempotent request.
+	//
+	// https://datatracker.ietf.org/doc/draft-ietf-httpapi-idempotency-key-header/
+	IdempotencyKey Header = "Idempotency-Key"
+
+	// The request quota associated with the client in the current time window.
+	//
+	// https://datatracker.ietf.org/doc/draft-ietf-httpapi-ratelimit-headers/
+	RateLimitLimit Header = "RateLimit-Limit"
+
+	// The remaining quota units associated with the client.
+	//
+	// https://datatracker.ietf.org/doc/draft-ietf-httpapi-ratelimit-headers/
+	RateLimitRemaining Header = "RateLimit-Remaining"
+
+	// The number of seconds until the quota associated with the client resets.
+	//
+	// https://datatracker.ietf.org/doc/draft-ietf-httpapi-ratelimit-headers/
+	RateLimitReset Header = "RateLimit-Reset"
+
+	// A unique identifier of the request used to correlate logs of the client and the server.
+	XRequestID Header = "X-Request-ID"
+
+	// Identifies the incoming request in a tracing system: trace ID, parent span ID, and trace flags.
+	//
+	// https://www.w3.org/TR/trace-context/#traceparent-header
+	Traceparent Header = "traceparent"
+
+	// Vendor-specific tracing data accompanying the "traceparent" header.
+	//
+	// https://www.w3.org/TR/trace-context/#tracestate-header
+	Tracestate Header = "tracestate"
+
+	// The time budget in seconds that the client has for the request.
+	//
+	// See [middlewares.Timeout] and [middlewares.PropagateTimeout].
+	//
+	// [middlewares.Timeout]: https://pkg.go.dev/github.com/orsinium-labs/josh/middlewares#Timeout
+	// [middlewares.PropagateTimeout]: https://pkg.go.dev/github.com/orsinium-labs/josh/middlewares#PropagateTimeout
+	RequestTimeout Header = "Request-Timeout"
+
+	// The CSRF token that must match the token in the "csrf_token" cookie.
+	//
+	// See [middlewares.CSRF].
+	//
+	// [middlewares.CSRF]: https://pkg.go.dev/github.com/orsinium-labs/josh/middlewares#CSRF
+	XCSRFToken Header = "X-CSRF-Token"
+
+	// A unique random value making each signed request different, used to detect replays.
+	//
+	// See [middlewares.HMAC].
+	//
+	// [middlewares.HMAC]: https://pkg.go.dev/github.com/orsinium-labs/josh/middlewares#HMAC
+	XNonce Header = "X-Nonce"
+
+	// The ID of the last server-sent event received by the client before reconnecting.
+	//
+	// https://html.spec.whatwg.org/multipage/server-sent-events.html#last-event-id
+	LastEventID Header = "Last-Event-ID"
+
+	// A random nonce sent by the client in the WebSocket opening handshake.
+	//
+	// https://developer.mozilla.org/en-US/docs/Web/HTTP/Headers/Sec-WebSocket-Key
+	SecWebSocketKey Header = "Sec-WebSocket-Key"
+
+	// The server response to "Sec-WebSocket-Key" confirming the WebSocket handshake.
+	//
+	// https://developer.mozilla.org/en-US/docs/Web/HTTP/Headers/Sec-WebSocket-Accept
+	SecWebSocketAccept Header = "Sec-WebSocket-Accept"
+
+	// The WebSocket protocol version used by the client or supported by the server.
+	//
+	// https://developer.mozilla.org/en-US/docs/Web/HTTP/Headers/Sec-WebSocket-Version
+	SecWebSocketVersion Header = "Sec-WebSocket-Version"
+
+	// The WebSocket subprotocols requested by the client or selected by the server.
+	//
+	// https://developer.mozilla.org/en-US/docs/Web/HTTP/Headers/Sec-WebSocket-Protocol
+	SecWebSocketProtocol Header = "Sec-WebSocket-Protocol"
+
+	// The WebSocket extensions requested by the client or selected by the server.
+	//
+	// https://developer.mozilla.org/en-US/docs/Web/HTTP/Headers/Sec-WebSocket-Extensions
+	SecWebSocketExtensions Header = "Sec-WebSocket-Extensions"
 )
@@ -0,0 +1,218 @@
+// Package health provides health, readiness, and liveness endpoints.
+//
+//	h := health.New()
+//	h.Add(health.Check{Name: "db", Check: db.PingContext, Critical: true})
+//	router := josh.Router{...}
+//	h.Register(router)
+package health
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/orsinium-labs/josh"
+	"github.com/orsinium-labs/josh/statuses"
+)
+
+// The default [Check.Timeout].
+const DefaultTimeout = 5 * time.Second
+
+// Check is a named health check.
+type Check struct {
+	// The check name shown in the response.
+	Name string
+
+	// The function performing the check. Returns an error if unhealthy.
+	Check func(context.Context) error
+
+	// How long the check can take. If zero, [DefaultTimeout] is used.
+	Timeout time.Duration
+
+	// If true and the check fails, the endpoint responds with 503.
+	//
+	// Failing non-critical checks are reported with the "warn" status.
+	Critical bool
+
+	// If true, the check is also run by the liveness endpoint.
+	//
+	// Liveness failures make the orchestrator restart the service,
+	// so only add checks that can be fixed by a restart, like a deadlock detector.
+	Live bool
+
+	// If not zero, the check result is reused for this long.
+	//
+	// Use it for expensive checks.
+	CacheFor time.Duration
+}
+
+// Result of a single check.
+type Result struct {
+	// "pass", "warn", or "fail".
+	Status string `json:"status"`
+
+	// Same as [Check.Critical].
+	Critical bool `json:"critical"`
+
+	// How long the check took.
+	Duration string `json:"duration"`
+
+	// The error message if the check failed.
+	Error string `json:"error,omitempty"`
+
+	// When the check was performed.
+	Time time.Time `json:"time"`
+}
+
+// Health is a collection of checks.
+//
+// Must be constructed using [New].
+type Health struct {
+	mu     sync.Mutex
+	checks []*entry
+}
+
+type entry struct {
+	Check
+	mu     sync.Mutex
+	cached *Result
+}
+
+// Create an empty [Health].
+func New() *Health {
+	return &Health{}
+}
+
+// Add a new check.
+func (h *Health) Add(c Check) {
+	if c.Timeout == 0 {
+		c.Timeout = DefaultTimeout
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.checks = append(h.checks, &entry{Check: c})
+}
+
+// Add the health endpoints into the router:
+//
+//   - /healthz and /readyz run all checks,
+//   - /livez runs only checks with [Check.Live].
+func (h *Health) Register(r josh.Router) {
+	r["/healthz"] = josh.Endpoint{GET: josh.Wrap(h.Ready)}
+	r["/readyz"] = josh.Endpoint{GET: josh.Wrap(h.Ready)}
+	r["/livez"] = josh.Endpoint{GET: josh.Wrap(h.Live)}
+}
+
+// Handler running all checks.
+func (h *Health) Ready(r josh.Req) josh.Resp {
+	return h.run(r.Context(), false)
+}
+
+// Handler running only liveness checks.
+func (h *Health) Live(r josh.Req) josh.Resp {
+	return h.run(r.Context(), true)
+}
+
+// Run all matching checks concurrently and build the response.
+func (h *Health) run(ctx context.Context, live bool) josh.Resp {
+	h.mu.Lock()
+	checks := make([]*entry, 0, len(h.checks))
+	for _, c := range h.checks {
+		if !live || c.Live {
+			checks = append(checks, c)
+		}
+	}
+	h.mu.Unlock()
+
+	results := make([]Result, len(checks))
+	wg := sync.WaitGroup{}
+	for i, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = c.run(ctx)
+		}()
+	}
+	wg.Wait()
+
+	status := "pass"
+	resp := josh.Resp{Status: statuses.OK}
+	byName := make(map[string]Result, len(checks))
+	for i, c := range checks {
+		res := results[i]
+		byName[c.Name] = res
+		if res.Status == "pass" {
+			continue
+		}
+		if c.Critical {
+			status = "fail"
+			resp.Status = statuses.ServiceUnavailable
+			resp.Errors = append(resp.Errors, josh.Error{
+				Title:  "Health check failed",
+				Detail: c.Name + ": " + res.Error,
+			})
+		} else if status == "pass" {
+			status = "warn"
+		}
+	}
+	resp.Meta = map[string]any{
+		"status": status,
+		"checks": byName,
+	}
+	return resp
+}
+
+// Run the check or return the cached result.
+func (e *entry) run(ctx context.Context) Result {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.cached != nil && time.Since(e.cached.Time) < e.CacheFor {
+		return *e.cached
+	}
+
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, e.Timeout)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- e.Check.Check(ctx)
+	}()
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = errors.New("timed out")
+	}
+
+	res := Result{
+		Status:   "pass",
+		Critical: e.Critical,
+		Duration: time.Since(start).String(),
+		Time:     start,
+	}
+	if err != nil {
+		res.Status = "fail"
+		if !e.Critical {
+			res.Status = "warn"
+		}
+		res.Error = err.Error()
+	}
+	if e.CacheFor > 0 {
+		e.cached = &res
+	}
+	return res
+}
+
+// Check that the [josh.Runner] is ready to accept requests.
+//
+// Add it as a critical check, so that the service is removed from
+// the load balancer as soon as the shutdown starts.
+func RunnerReady(runner *josh.Runner) func(context.Context) error {
+	return func(context.Context) error {
+		if !runner.Ready() {
+			return errors.New("the server is not ready")
+		}
+		return nil
+	}
+}
@@ -0,0 +1,101 @@
+package health_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/orsinium-labs/josh"
+	"github.com/orsinium-labs/josh/health"
+)
+
+func eq[T comparable](a, b T) {
+	if a != b {
+		panic(fmt.Sprintf("%v != %v", a, b))
+	}
+}
+
+type response struct {
+	Errors []josh.Error `json:"errors"`
+	Meta   struct {
+		Status string                   `json:"status"`
+		Checks map[string]health.Result `json:"checks"`
+	} `json:"meta"`
+}
+
+func get(t *testing.T, mux *http.ServeMux, path string) (int, response) {
+	t.Helper()
+	req := httptest.NewRequest("GET", "http://example.com"+path, nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	var resp response
+	err := json.Unmarshal(w.Body.Bytes(), &resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w.Code, resp
+}
+
+func TestHealth(t *testing.T) {
+	var dbErr error
+	calls := 0
+	h := health.New()
+	h.Add(health.Check{
+		Name:     "db",
+		Critical: true,
+		Check: func(context.Context) error {
+			return dbErr
+		},
+	})
+	h.Add(health.Check{
+		Name:     "cache",
+		Live:     true,
+		CacheFor: time.Minute,
+		Check: func(context.Context) error {
+			calls += 1
+			return errors.New("oh no")
+		},
+	})
+	h.Add(health.Check{
+		Name:     "slow",
+		Timeout:  time.Millisecond,
+		Critical: false,
+		Check: func(ctx context.Context) error {
+			time.Sleep(100 * time.Millisecond)
+			return nil
+		},
+	})
+	router := josh.Router{}
+	h.Register(router)
+	mux := http.NewServeMux()
+	router.Register(mux)
+
+	status, resp := get(t, mux, "/readyz")
+	eq(status, 200)
+	eq(resp.Meta.Status, "warn")
+	eq(resp.Meta.Checks["db"].Status, "pass")
+	eq(resp.Meta.Checks["cache"].Status, "warn")
+	eq(resp.Meta.Checks["cache"].Error, "oh no")
+	eq(resp.Meta.Checks["slow"].Error, "timed out")
+
+	dbErr = errors.New("connection refused")
+	status, resp = get(t, mux, "/healthz")
+	eq(status, 503)
+	eq(resp.Meta.Status, "fail")
+	eq(resp.Meta.Checks["db"].Status, "fail")
+	eq(len(resp.Errors), 1)
+	eq(resp.Errors[0].Detail, "db: connection refused")
+
+	status, resp = get(t, mux, "/livez")
+	eq(status, 200)
+	eq(len(resp.Meta.Checks), 1)
+	eq(resp.Meta.Checks["cache"].Status, "warn")
+
+	// The cache check result is reused.
+	eq(calls, 1)
+}
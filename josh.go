@@ -16,7 +16,10 @@ import (
 
 type contextKey string
 
-const headersKey contextKey = "headers"
+const (
+	headersKey     contextKey = "headers"
+	interceptorKey contextKey = "interceptor"
+)
 
 // Req is an alias for a pointer to [http.Request].
 type Req = *http.Request
@@ -42,16 +45,46 @@ type Handler func(Req) Resp
 // For going the other way around, see [Unwrap].
 func Wrap(h Handler) http.HandlerFunc {
 	return func(w http.ResponseWriter, r Req) {
+		state := &interceptor{w: w}
 		ctx := context.WithValue(r.Context(), headersKey, w.Header())
+		ctx = context.WithValue(ctx, interceptorKey, state)
 		r = r.WithContext(ctx)
 		r, _ = WithSingleton(r, w)
 		resp := h(r)
 		if !Canceled(r) {
-			resp.Write(w)
+			resp.Write(state.w)
+		}
+		for i := len(state.after) - 1; i >= 0; i-- {
+			state.after[i]()
 		}
 	}
 }
 
+// The state shared by [Wrap] with middlewares calling [Intercept].
+type interceptor struct {
+	w     http.ResponseWriter
+	after []func()
+}
+
+// Make [Wrap] write the response into the given writer and call the function after that.
+//
+// The writer also replaces the [http.ResponseWriter] singleton, so that
+// the handler writes into it too. The function is called when the [Resp]
+// returned by the handler is written or discarded because the request is canceled.
+// It allows middlewares to observe the response that was actually sent,
+// like the status code and the number of written bytes.
+//
+// Returns an error if the request doesn't come from [Wrap].
+func Intercept(r Req, w http.ResponseWriter, after func()) (Req, error) {
+	state, ok := r.Context().Value(interceptorKey).(*interceptor)
+	if !ok {
+		return r, errors.New("the request doesn't come from josh.Wrap")
+	}
+	state.w = w
+	state.after = append(state.after, after)
+	return ReplaceSingleton(r, w), nil
+}
+
 // Adapter making [http.HandlerFunc] compatible with josh.
 //
 // For going the other way around, see [Wrap].
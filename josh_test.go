@@ -128,3 +128,34 @@ func TestGetOrSetSingleton(t *testing.T) {
 	body := must(io.ReadAll(resp.Body))
 	eq(string(body), `{"data":"ok"}`+"\n")
 }
+
+func TestReplaceSingleton(t *testing.T) {
+	type User struct{ name string }
+	req := httptest.NewRequest("GET", "http://example.com/foo", nil)
+	req = josh.ReplaceSingleton(req, User{"aragorn"})
+	eq(josh.Must(josh.GetSingleton[User](req)).name, "aragorn")
+	req = josh.ReplaceSingleton(req, User{"gandalf"})
+	eq(josh.Must(josh.GetSingleton[User](req)).name, "gandalf")
+}
+
+func TestIntercept(t *testing.T) {
+	var written string
+	h := josh.Wrap(func(r josh.Req) josh.Resp {
+		w := josh.Must(josh.GetSingleton[http.ResponseWriter](r))
+		rec := httptest.NewRecorder()
+		r = josh.Must(josh.Intercept(r, rec, func() {
+			written = rec.Body.String()
+			_, _ = w.Write(rec.Body.Bytes())
+		}))
+		eq(josh.Must(josh.GetSingleton[http.ResponseWriter](r)) == http.ResponseWriter(rec), true)
+		return josh.Ok("hi")
+	})
+	req := httptest.NewRequest("GET", "http://example.com/foo", nil)
+	w := httptest.NewRecorder()
+	h(w, req)
+	eq(written, `{"data":"hi"}`+"\n")
+	eq(w.Body.String(), written)
+
+	_, err := josh.Intercept(req, w, func() {})
+	eq(err != nil, true)
+}
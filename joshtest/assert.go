@@ -0,0 +1,216 @@
+package joshtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/orsinium-labs/josh/headers"
+	"github.com/orsinium-labs/josh/middlewares"
+	"github.com/orsinium-labs/josh/statuses"
+)
+
+// Check that the response has the given status code.
+func (r *Response) ExpectStatus(status statuses.Status) *Response {
+	r.t.Helper()
+	if r.Status != int(status) {
+		r.t.Errorf("status: got %d, want %d\nbody: %s", r.Status, status, r.Body)
+	}
+	return r
+}
+
+// Check that the response header has the given value.
+func (r *Response) ExpectHeader(h headers.Header, value string) *Response {
+	r.t.Helper()
+	got := r.Get(h)
+	if got != value {
+		r.t.Errorf("header %s: got %q, want %q", h, got, value)
+	}
+	return r
+}
+
+// Check that the response body is a valid JSON:API document.
+//
+// See [middlewares.ValidateDocument] for the checked rules.
+func (r *Response) ExpectValid() *Response {
+	r.t.Helper()
+	violations := middlewares.ValidateDocument(r.Body)
+	if len(violations) == 0 {
+		return r
+	}
+	lines := make([]string, len(violations))
+	for i, v := range violations {
+		lines[i] = v.String()
+	}
+	r.t.Errorf("invalid JSON:API document:\n  %s\nbody: %s", strings.Join(lines, "\n  "), r.Body)
+	return r
+}
+
+// Check that the response body is equal to the given JSON, ignoring key order and formatting.
+func (r *Response) ExpectJSON(expected string) *Response {
+	r.t.Helper()
+	want, err := normalizeJSON([]byte(expected))
+	if err != nil {
+		r.t.Fatalf("invalid expected JSON: %v", err)
+	}
+	got, err := normalizeJSON(r.Body)
+	if err != nil {
+		r.t.Errorf("response is not valid JSON: %v\nbody: %s", err, r.Body)
+		return r
+	}
+	if got != want {
+		r.t.Errorf("body mismatch (-want +got):\n%s", diffLines(want, got))
+	}
+	return r
+}
+
+// Check that the "data" member of the response is equal to the given value.
+//
+// The value is JSON-encoded and compared ignoring key order,
+// so it can be a struct, like [josh.Data], a map, or a [json.RawMessage].
+func (r *Response) ExpectData(expected any) *Response {
+	r.t.Helper()
+	raw, err := json.Marshal(expected)
+	if err != nil {
+		r.t.Fatalf("encode expected data: %v", err)
+	}
+	want, err := normalizeJSON(raw)
+	if err != nil {
+		r.t.Fatalf("invalid expected data: %v", err)
+	}
+	var doc struct {
+		Data json.RawMessage `json:"data"`
+	}
+	err = json.Unmarshal(r.Body, &doc)
+	if err != nil {
+		r.t.Errorf("response is not a JSON object: %v\nbody: %s", err, r.Body)
+		return r
+	}
+	if doc.Data == nil {
+		r.t.Errorf("response has no data\nbody: %s", r.Body)
+		return r
+	}
+	got, _ := normalizeJSON(doc.Data)
+	if got != want {
+		r.t.Errorf("data mismatch (-want +got):\n%s", diffLines(want, got))
+	}
+	return r
+}
+
+// Check that the response has an error with the given code.
+//
+// If pointer is not empty, the error must also have
+// the "source.pointer" equal to it.
+func (r *Response) ExpectError(code, pointer string) *Response {
+	r.t.Helper()
+	var doc struct {
+		Errors []struct {
+			Code   string `json:"code"`
+			Source struct {
+				Pointer string `json:"pointer"`
+			} `json:"source"`
+		} `json:"errors"`
+	}
+	err := json.Unmarshal(r.Body, &doc)
+	if err != nil {
+		r.t.Errorf("response is not a JSON object: %v\nbody: %s", err, r.Body)
+		return r
+	}
+	var found []string
+	for _, e := range doc.Errors {
+		if e.Code == code && (pointer == "" || e.Source.Pointer == pointer) {
+			return r
+		}
+		found = append(found, fmt.Sprintf("code=%q pointer=%q", e.Code, e.Source.Pointer))
+	}
+	want := fmt.Sprintf("code=%q", code)
+	if pointer != "" {
+		want += fmt.Sprintf(" pointer=%q", pointer)
+	}
+	if len(found) == 0 {
+		r.t.Errorf("no error with %s: response has no errors", want)
+	} else {
+		r.t.Errorf("no error with %s, found:\n  %s", want, strings.Join(found, "\n  "))
+	}
+	return r
+}
+
+// Re-encode JSON with sorted keys and indentation, so it can be compared and diffed.
+func normalizeJSON(raw []byte) (string, error) {
+	v, err := decodeJSON(raw)
+	if err != nil {
+		return "", err
+	}
+	return indentJSON(v)
+}
+
+// Decode a single JSON value, keeping numbers as written.
+//
+// Unlike [json.Unmarshal], large integers don't lose precision
+// and "1" and "1.0" are not considered equal.
+func decodeJSON(raw []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var v any
+	err := dec.Decode(&v)
+	if err != nil {
+		return nil, err
+	}
+	_, err = dec.Token()
+	if err != io.EOF {
+		return nil, errors.New("unexpected data after the JSON value")
+	}
+	return v, nil
+}
+
+// Encode the value as indented JSON without escaping HTML characters.
+//
+// Maps are encoded with sorted keys.
+func indentJSON(v any) (string, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	err := enc.Encode(v)
+	return strings.TrimSuffix(buf.String(), "\n"), err
+}
+
+// Produce a line diff of two texts, prefixing removed lines with "-" and added with "+".
+func diffLines(want, got string) string {
+	a := strings.Split(want, "\n")
+	b := strings.Split(got, "\n")
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	var out strings.Builder
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			out.WriteString("  " + a[i] + "\n")
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] >= lcs[i+1][j]):
+			out.WriteString("+ " + b[j] + "\n")
+			j++
+		default:
+			out.WriteString("- " + a[i] + "\n")
+			i++
+		}
+	}
+	return out.String()
+}
@@ -0,0 +1,109 @@
+package joshtest_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/orsinium-labs/josh"
+	"github.com/orsinium-labs/josh/joshtest"
+)
+
+// A testing.TB that records failures instead of failing the test.
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func getUser(r josh.Req) josh.Resp {
+	josh.SetHeader(r, "Cache-Control", "no-store")
+	return josh.Ok(josh.Data[User]{
+		ID:         "1",
+		Type:       "user",
+		Attributes: User{Name: "Frodo"},
+	})
+}
+
+func badUser(r josh.Req) josh.Resp {
+	return josh.BadRequest(josh.Error{
+		Code:   "invalid",
+		Source: josh.SourcePointer("/data/attributes/name"),
+	})
+}
+
+func TestExpect_Ok(t *testing.T) {
+	c := joshtest.NewClient(t, getUser)
+	c.Get("/").
+		ExpectStatus(200).
+		ExpectHeader("Cache-Control", "no-store").
+		ExpectValid().
+		ExpectJSON(`{"data": {"type": "user", "id": "1", "attributes": {"name": "Frodo"}}}`).
+		ExpectData(map[string]any{
+			"attributes": map[string]string{"name": "Frodo"},
+			"id":         "1",
+			"type":       "user",
+		})
+
+	c = joshtest.NewClient(t, badUser)
+	c.Get("/").
+		ExpectStatus(400).
+		ExpectValid().
+		ExpectError("invalid", "").
+		ExpectError("invalid", "/data/attributes/name")
+}
+
+func TestExpect_Fail(t *testing.T) {
+	rec := &recorder{TB: t}
+	c := joshtest.NewClient(rec, getUser)
+	c.Get("/").
+		ExpectStatus(201).
+		ExpectHeader("Cache-Control", "no-cache").
+		ExpectData(josh.Data[User]{ID: "1", Type: "user", Attributes: User{Name: "Sam"}}).
+		ExpectError("invalid", "")
+	eq(len(rec.errors), 4)
+	eq(rec.errors[0], "status: got 200, want 201\nbody: "+`{"data":{"id":"1","type":"user","attributes":{"name":"Frodo"}}}`+"\n")
+	eq(rec.errors[1], `header Cache-Control: got "no-store", want "no-cache"`)
+	eq(strings.Contains(rec.errors[2], `-     "name": "Sam"`), true)
+	eq(strings.Contains(rec.errors[2], `+     "name": "Frodo"`), true)
+	eq(strings.Contains(rec.errors[2], `    "id": "1",`), true)
+	eq(rec.errors[3], `no error with code="invalid": response has no errors`)
+
+	rec = &recorder{TB: t}
+	c = joshtest.NewClient(rec, badUser)
+	c.Get("/").ExpectError("invalid", "/data/id")
+	eq(rec.errors[0], "no error with code=\"invalid\" pointer=\"/data/id\", found:\n  code=\"invalid\" pointer=\"/data/attributes/name\"")
+}
+
+func TestExpectJSON_LargeNumbers(t *testing.T) {
+	h := func(r josh.Req) josh.Resp {
+		return josh.Ok(map[string]int64{"id": 9007199254740993})
+	}
+	rec := &recorder{TB: t}
+	c := joshtest.NewClient(rec, h)
+	// Both numbers are 9007199254740992 when parsed as float64.
+	c.Get("/").ExpectJSON(`{"data": {"id": 9007199254740992}}`)
+	eq(len(rec.errors), 1)
+
+	rec = &recorder{TB: t}
+	c = joshtest.NewClient(rec, h)
+	c.Get("/").
+		ExpectJSON(`{"data": {"id": 9007199254740993}}`).
+		ExpectData(map[string]int64{"id": 9007199254740993})
+	eq(len(rec.errors), 0)
+}
+
+func TestExpectValid(t *testing.T) {
+	joshtest.NewClient(t, getUser).Get("/").ExpectValid()
+
+	rec := &recorder{TB: t}
+	hello := func(r josh.Req) josh.Resp { return josh.Ok("hello") }
+	joshtest.NewClient(rec, hello).Get("/").ExpectValid()
+	eq(len(rec.errors), 1)
+	eq(strings.Contains(rec.errors[0], "\n  /data: data must be null"), true)
+}
@@ -0,0 +1,200 @@
+package joshtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/orsinium-labs/josh"
+	"github.com/orsinium-labs/josh/headers"
+)
+
+// Client sends requests to a handler in-process, without starting a server.
+//
+// Must be constructed using [NewClient].
+type Client struct {
+	// Headers added to every request, like "Authorization".
+	Header http.Header
+
+	t       testing.TB
+	handler http.Handler
+}
+
+// Create a [Client] for the given handler.
+//
+// The handler can be [josh.Handler], [josh.Router], [josh.Endpoint],
+// [http.Handler], or [http.HandlerFunc] (or an equivalent function).
+func NewClient(t testing.TB, h any) *Client {
+	t.Helper()
+	var handler http.Handler
+	switch h := h.(type) {
+	case josh.Handler:
+		handler = josh.Wrap(h)
+	case func(josh.Req) josh.Resp:
+		handler = josh.Wrap(h)
+	case josh.Router:
+		mux := http.NewServeMux()
+		h.Register(mux)
+		handler = mux
+	case josh.Endpoint:
+		mux := http.NewServeMux()
+		josh.Router{"/": h}.Register(mux)
+		handler = mux
+	case http.Handler:
+		handler = h
+	case func(http.ResponseWriter, *http.Request):
+		handler = http.HandlerFunc(h)
+	default:
+		t.Fatalf("joshtest.NewClient: unsupported handler type %T", h)
+	}
+	return &Client{
+		Header:  make(http.Header),
+		t:       t,
+		handler: handler,
+	}
+}
+
+// Send a GET request.
+func (c *Client) Get(path string) *Response {
+	c.t.Helper()
+	return c.Do(http.MethodGet, path, nil)
+}
+
+// Send a POST request with the given body. See [Client.Do].
+func (c *Client) Post(path string, body any) *Response {
+	c.t.Helper()
+	return c.Do(http.MethodPost, path, body)
+}
+
+// Send a PATCH request with the given body. See [Client.Do].
+func (c *Client) Patch(path string, body any) *Response {
+	c.t.Helper()
+	return c.Do(http.MethodPatch, path, body)
+}
+
+// Send a DELETE request.
+func (c *Client) Delete(path string) *Response {
+	c.t.Helper()
+	return c.Do(http.MethodDelete, path, nil)
+}
+
+// Send a request with the given method, path, and body.
+//
+// If the body is []byte, string, or [json.RawMessage], it's sent as is.
+// Otherwise, it's JSON-encoded and wrapped into the "data" member
+// of a JSON:API document. Typically, it's a [josh.Data] instance.
+func (c *Client) Do(method, path string, body any) *Response {
+	c.t.Helper()
+	var reader io.Reader
+	switch body := body.(type) {
+	case nil:
+	case []byte:
+		reader = bytes.NewReader(body)
+	case string:
+		reader = bytes.NewReader([]byte(body))
+	case json.RawMessage:
+		reader = bytes.NewReader(body)
+	default:
+		raw, err := json.Marshal(map[string]any{"data": body})
+		if err != nil {
+			c.t.Fatalf("joshtest: encode request body: %v", err)
+		}
+		reader = bytes.NewReader(raw)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	if reader != nil {
+		req.Header.Set(string(headers.ContentType), "application/vnd.api+json")
+	}
+	return c.Send(req)
+}
+
+// Send the given request.
+//
+// The default headers of the client are added to the request
+// unless the request already has them.
+func (c *Client) Send(req *http.Request) *Response {
+	c.t.Helper()
+	for key, values := range c.Header {
+		if req.Header.Get(key) == "" {
+			req.Header[key] = values
+		}
+	}
+	w := httptest.NewRecorder()
+	c.handler.ServeHTTP(w, req)
+	resp := w.Result()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		c.t.Fatalf("joshtest: read response body: %v", err)
+	}
+	return &Response{
+		Status: resp.StatusCode,
+		Header: resp.Header,
+		Body:   body,
+		t:      c.t,
+	}
+}
+
+// Response is the response received by [Client].
+type Response struct {
+	// The response status code.
+	Status int
+
+	// The response headers.
+	Header http.Header
+
+	// The raw response body.
+	Body []byte
+
+	t testing.TB
+}
+
+// Get the value of the response header.
+func (r *Response) Get(h headers.Header) string {
+	return r.Header.Get(string(h))
+}
+
+// Decode the response body as JSON into v. Fails the test if it can't be decoded.
+func (r *Response) JSON(v any) {
+	r.t.Helper()
+	err := json.Unmarshal(r.Body, v)
+	if err != nil {
+		r.t.Fatalf("joshtest: decode response body: %v\nbody: %s", err, r.Body)
+	}
+}
+
+// Get the errors from the JSON:API error response.
+//
+// Returns nil if the response has no errors.
+func (r *Response) Errors() []josh.Error {
+	r.t.Helper()
+	if len(r.Body) == 0 {
+		return nil
+	}
+	var doc struct {
+		Errors []josh.Error `json:"errors"`
+	}
+	r.JSON(&doc)
+	return doc.Errors
+}
+
+// Decode the "data" member of the JSON:API response.
+//
+// Use it for responses where data is not a resource object, like [josh.Ok]("hi").
+// For resource objects, see [ReadResource].
+func ReadData[T any](r *Response) T {
+	r.t.Helper()
+	var doc struct {
+		Data T `json:"data"`
+	}
+	r.JSON(&doc)
+	return doc.Data
+}
+
+// Decode the resource object in the "data" member of the JSON:API response.
+func ReadResource[T any](r *Response) josh.Data[T] {
+	r.t.Helper()
+	return ReadData[josh.Data[T]](r)
+}
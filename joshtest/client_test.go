@@ -0,0 +1,61 @@
+package joshtest_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/orsinium-labs/josh"
+	"github.com/orsinium-labs/josh/joshtest"
+)
+
+func eq[T comparable](a, b T) {
+	if a != b {
+		panic(fmt.Sprintf("%v != %v", a, b))
+	}
+}
+
+type User struct {
+	Name string `json:"name"`
+}
+
+func createUser(r josh.Req) josh.Resp {
+	user, err := josh.Read[User]("user", r.Body)
+	if err != nil {
+		return josh.BadRequest(josh.Error{Code: "invalid", Detail: err.Error()})
+	}
+	user.ID = "1"
+	josh.SetHeader(r, "Location", "/users/1")
+	return josh.Created(user)
+}
+
+func TestClient(t *testing.T) {
+	c := joshtest.NewClient(t, josh.Router{
+		"/users": {POST: josh.Wrap(createUser)},
+	})
+	resp := c.Post("/users", josh.Data[User]{Type: "user", Attributes: User{Name: "Frodo"}})
+	eq(resp.Status, 201)
+	eq(resp.Get("Location"), "/users/1")
+	user := joshtest.ReadResource[User](resp)
+	eq(user.ID, "1")
+	eq(user.Attributes.Name, "Frodo")
+	eq(len(resp.Errors()), 0)
+
+	resp = c.Post("/users", `{"data": {"type": "orc"}}`)
+	eq(resp.Status, 400)
+	eq(resp.Errors()[0].Code, "invalid")
+
+	eq(c.Get("/users").Status, 405)
+}
+
+func TestClient_Handlers(t *testing.T) {
+	greet := func(r josh.Req) josh.Resp {
+		return josh.Ok("hello " + r.Header.Get("X-Name"))
+	}
+	c := joshtest.NewClient(t, greet)
+	c.Header.Set("X-Name", "Sam")
+	eq(joshtest.ReadData[string](c.Get("/")), "hello Sam")
+
+	c = joshtest.NewClient(t, http.NotFoundHandler())
+	eq(c.Delete("/").Status, 404)
+}
@@ -0,0 +1,121 @@
+package joshtest
+
+import (
+	"errors"
+	"flag"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// The value that redacted fields are replaced with in snapshots.
+const Redacted = "<redacted>"
+
+// Registered only in test binaries, so that it doesn't pollute flags of the app.
+// Namespaced, so that it doesn't clash with "-update" flags of other packages.
+var update = registerUpdateFlag()
+
+func registerUpdateFlag() *bool {
+	if !testing.Testing() {
+		return new(bool)
+	}
+	return flag.Bool("joshtest.update", false, "update joshtest golden files")
+}
+
+// Check if golden files should be updated instead of compared.
+func updating() bool {
+	return *update || os.Getenv("JOSHTEST_UPDATE") == "1"
+}
+
+// Compare the response body with the golden file "testdata/<name>.golden".
+//
+// The body is normalized: keys are sorted and the JSON is indented.
+// Volatile fields, like IDs and timestamps, can be replaced with [Redacted]
+// by passing JSON pointers to them. A pointer segment "*" matches
+// any key or index, like "/data/*/id".
+//
+// To create or regenerate golden files, run tests with the "-joshtest.update" flag
+// or, to update all packages at once, with JOSHTEST_UPDATE=1 environment variable:
+//
+//	JOSHTEST_UPDATE=1 go test ./...
+func (r *Response) ExpectSnapshot(name string, redact ...string) *Response {
+	r.t.Helper()
+	body, err := decodeJSON(r.Body)
+	if err != nil {
+		r.t.Errorf("response is not valid JSON: %v\nbody: %s", err, r.Body)
+		return r
+	}
+	for _, pointer := range redact {
+		body = redactPointer(body, parsePointer(pointer))
+	}
+	got, err := indentJSON(body)
+	if err != nil {
+		r.t.Fatalf("encode snapshot: %v", err)
+	}
+	got += "\n"
+
+	path := filepath.Join("testdata", name+".golden")
+	if updating() {
+		err = os.MkdirAll(filepath.Dir(path), 0o755)
+		if err == nil {
+			err = os.WriteFile(path, []byte(got), 0o644)
+		}
+		if err != nil {
+			r.t.Fatalf("update golden file: %v", err)
+		}
+		return r
+	}
+	want, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		r.t.Errorf("golden file %s does not exist, run tests with -joshtest.update to create it", path)
+		return r
+	}
+	if err != nil {
+		r.t.Fatalf("read golden file: %v", err)
+	}
+	if string(want) != got {
+		r.t.Errorf("snapshot %s mismatch (-want +got):\n%s", path, diffLines(string(want), got))
+	}
+	return r
+}
+
+// Split the JSON pointer (RFC 6901) into unescaped segments.
+func parsePointer(pointer string) []string {
+	if pointer == "" {
+		return nil
+	}
+	parts := strings.Split(strings.TrimPrefix(pointer, "/"), "/")
+	for i, part := range parts {
+		part = strings.ReplaceAll(part, "~1", "/")
+		parts[i] = strings.ReplaceAll(part, "~0", "~")
+	}
+	return parts
+}
+
+// Replace the values matching the pointer segments with [Redacted].
+//
+// Missing paths are ignored.
+func redactPointer(v any, path []string) any {
+	if len(path) == 0 {
+		return Redacted
+	}
+	key, rest := path[0], path[1:]
+	switch v := v.(type) {
+	case map[string]any:
+		for k, item := range v {
+			if key == "*" || key == k {
+				v[k] = redactPointer(item, rest)
+			}
+		}
+	case []any:
+		for i, item := range v {
+			if key == "*" || key == strconv.Itoa(i) {
+				v[i] = redactPointer(item, rest)
+			}
+		}
+	}
+	return v
+}
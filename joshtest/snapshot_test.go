@@ -0,0 +1,46 @@
+package joshtest_test
+
+import (
+	"flag"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/orsinium-labs/josh"
+	"github.com/orsinium-labs/josh/joshtest"
+)
+
+func listUsers(r josh.Req) josh.Resp {
+	now := time.Now().UnixNano()
+	return josh.Resp{
+		Data: []josh.Data[User]{
+			{ID: time.Now().String(), Type: "user", Attributes: User{Name: "Frodo"}},
+			{ID: time.Now().Format(time.RFC3339Nano), Type: "user", Attributes: User{Name: "Sam"}},
+		},
+		Meta: map[string]any{"generated/at": now, "total": 2},
+	}
+}
+
+func TestExpectSnapshot(t *testing.T) {
+	c := joshtest.NewClient(t, listUsers)
+	c.Get("/").ExpectSnapshot("users", "/data/*/id", "/meta/generated~1at", "/missing/path")
+}
+
+func TestExpectSnapshot_Fail(t *testing.T) {
+	rec := &recorder{TB: t}
+	c := joshtest.NewClient(rec, listUsers)
+	c.Get("/").ExpectSnapshot("users", "/data/0/id", "/meta/generated~1at")
+	eq(len(rec.errors), 1)
+	eq(strings.Contains(rec.errors[0], `-       "id": "<redacted>",`), true)
+
+	rec = &recorder{TB: t}
+	c = joshtest.NewClient(rec, listUsers)
+	c.Get("/").ExpectSnapshot("missing")
+	eq(rec.errors[0], "golden file testdata/missing.golden does not exist, run tests with -joshtest.update to create it")
+}
+
+func TestExpectSnapshot_Flag(t *testing.T) {
+	// The generic name is left for other packages.
+	eq(flag.Lookup("update"), nil)
+	eq(flag.Lookup("joshtest.update") != nil, true)
+}
@@ -0,0 +1,260 @@
+// Package jwt verifies JSON Web Tokens using only the standard library.
+//
+// Supported algorithms are HS256, RS256, ES256, and EdDSA (Ed25519).
+//
+//	keys := jwt.NewJWKS("https://auth.example.com/.well-known/jwks.json", time.Hour)
+//	v := jwt.Validator[Claims](jwt.Config{Keys: keys, Issuer: "https://auth.example.com"})
+//	h = middlewares.Auth(v, h)
+//
+// https://datatracker.ietf.org/doc/html/rfc7519
+package jwt
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/orsinium-labs/josh/middlewares"
+)
+
+var (
+	ErrMalformed        = errors.New("malformed token")
+	ErrAlgorithm        = errors.New("unsupported signing algorithm")
+	ErrSignature        = errors.New("invalid token signature")
+	ErrExpired          = errors.New("token is expired")
+	ErrNotYetValid      = errors.New("token is not valid yet")
+	ErrIssuer           = errors.New("invalid token issuer")
+	ErrAudience         = errors.New("invalid token audience")
+	ErrMissingExpiresAt = errors.New("token has no expiration time")
+)
+
+// Algorithms supported by default.
+var DefaultAlgorithms = []string{"HS256", "RS256", "ES256", "EdDSA"}
+
+// Config for verifying tokens.
+type Config struct {
+	// The keys to verify the token signature. Required.
+	Keys KeySet
+
+	// If not empty, the "iss" claim must be equal to it.
+	Issuer string
+
+	// If not empty, the "aud" claim must contain it.
+	Audience string
+
+	// Allowed clock skew when checking "exp" and "nbf".
+	Leeway time.Duration
+
+	// Allowed signing algorithms. If nil, [DefaultAlgorithms] is used.
+	//
+	// Restrict it to the algorithms you actually use.
+	Algorithms []string
+
+	// The function returning the current time. If nil, [time.Now] is used.
+	Now func() time.Time
+}
+
+// Create a validator for [middlewares.Auth] that verifies the token
+// and decodes its claims into C.
+//
+// The token must have the "exp" claim.
+func Validator[C any](cfg Config) middlewares.AuthValidator[C] {
+	return func(token string) (C, error) {
+		var claims C
+		err := cfg.Verify(token, &claims)
+		return claims, err
+	}
+}
+
+type header struct {
+	Alg  string   `json:"alg"`
+	Kid  string   `json:"kid"`
+	Crit []string `json:"crit"`
+}
+
+// The claims registered in RFC 7519 that are checked by [Config.Verify].
+type registered struct {
+	Issuer    string       `json:"iss"`
+	Audience  audience     `json:"aud"`
+	ExpiresAt *numericDate `json:"exp"`
+	NotBefore *numericDate `json:"nbf"`
+}
+
+// Verify the token signature and claims and decode the claims into v.
+func (cfg Config) Verify(token string, v any) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return ErrMalformed
+	}
+	var h header
+	err := decodeSegment(parts[0], &h)
+	if err != nil {
+		return err
+	}
+	algs := cfg.Algorithms
+	if algs == nil {
+		algs = DefaultAlgorithms
+	}
+	if !slices.Contains(algs, h.Alg) {
+		return fmt.Errorf("%w: %q", ErrAlgorithm, h.Alg)
+	}
+	if len(h.Crit) != 0 {
+		return fmt.Errorf("%w: unsupported critical headers", ErrMalformed)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	if cfg.Keys == nil {
+		return errors.New("no keys configured")
+	}
+	keys, err := cfg.Keys.Keys(h.Kid)
+	if err != nil {
+		return err
+	}
+	signed := []byte(parts[0] + "." + parts[1])
+	verified := false
+	for _, key := range keys {
+		if key.Algorithm != "" && key.Algorithm != h.Alg {
+			continue
+		}
+		if verify(h.Alg, key.Key, signed, sig) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return ErrSignature
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	var reg registered
+	err = json.Unmarshal(payload, &reg)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	err = cfg.checkClaims(reg)
+	if err != nil {
+		return err
+	}
+	if v == nil {
+		return nil
+	}
+	err = json.Unmarshal(payload, v)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	return nil
+}
+
+func (cfg Config) checkClaims(reg registered) error {
+	now := time.Now()
+	if cfg.Now != nil {
+		now = cfg.Now()
+	}
+	if reg.ExpiresAt == nil {
+		return ErrMissingExpiresAt
+	}
+	if !now.Before(reg.ExpiresAt.Time().Add(cfg.Leeway)) {
+		return ErrExpired
+	}
+	if reg.NotBefore != nil && now.Add(cfg.Leeway).Before(reg.NotBefore.Time()) {
+		return ErrNotYetValid
+	}
+	if cfg.Issuer != "" && reg.Issuer != cfg.Issuer {
+		return ErrIssuer
+	}
+	if cfg.Audience != "" && !slices.Contains(reg.Audience, cfg.Audience) {
+		return ErrAudience
+	}
+	return nil
+}
+
+// Check the signature using the given algorithm and key.
+func verify(alg string, key any, signed, sig []byte) bool {
+	switch alg {
+	case "HS256":
+		secret, ok := key.([]byte)
+		if !ok {
+			return false
+		}
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(signed)
+		return hmac.Equal(mac.Sum(nil), sig)
+	case "RS256":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return false
+		}
+		hash := sha256.Sum256(signed)
+		return rsa.VerifyPKCS1v15(pub, crypto.SHA256, hash[:], sig) == nil
+	case "ES256":
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok || len(sig) != 64 {
+			return false
+		}
+		hash := sha256.Sum256(signed)
+		r := new(big.Int).SetBytes(sig[:32])
+		s := new(big.Int).SetBytes(sig[32:])
+		return ecdsa.Verify(pub, hash[:], r, s)
+	case "EdDSA":
+		pub, ok := key.(ed25519.PublicKey)
+		if !ok || len(pub) != ed25519.PublicKeySize {
+			return false
+		}
+		return ed25519.Verify(pub, signed, sig)
+	}
+	return false
+}
+
+func decodeSegment(seg string, v any) error {
+	raw, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	err = decoder.Decode(v)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	return nil
+}
+
+// Seconds since the Unix epoch, possibly fractional.
+type numericDate float64
+
+func (d numericDate) Time() time.Time {
+	sec, frac := math.Modf(float64(d))
+	return time.Unix(int64(sec), int64(frac*1e9))
+}
+
+// The "aud" claim which can be either a string or an array of strings.
+type audience []string
+
+func (a *audience) UnmarshalJSON(b []byte) error {
+	var single string
+	if json.Unmarshal(b, &single) == nil {
+		*a = audience{single}
+		return nil
+	}
+	var many []string
+	err := json.Unmarshal(b, &many)
+	*a = many
+	return err
+}
@@ -0,0 +1,212 @@
+package jwt_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/orsinium-labs/josh"
+	"github.com/orsinium-labs/josh/jwt"
+)
+
+func eq[T comparable](a, b T) {
+	if a != b {
+		panic(fmt.Sprintf("%v != %v", a, b))
+	}
+}
+
+func must2[A, B any](a A, b B, err error) (A, B) {
+	if err != nil {
+		panic(err)
+	}
+	return a, b
+}
+
+type Claims struct {
+	Subject string `json:"sub"`
+	Admin   bool   `json:"admin"`
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Create a signed token.
+func sign(t *testing.T, alg, kid string, key any, claims map[string]any) string {
+	t.Helper()
+	h := josh.Must(json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"}))
+	p := josh.Must(json.Marshal(claims))
+	signed := b64(h) + "." + b64(p)
+	hash := sha256.Sum256([]byte(signed))
+	var sig []byte
+	switch alg {
+	case "HS256":
+		mac := hmac.New(sha256.New, key.([]byte))
+		mac.Write([]byte(signed))
+		sig = mac.Sum(nil)
+	case "RS256":
+		sig = josh.Must(rsa.SignPKCS1v15(rand.Reader, key.(*rsa.PrivateKey), crypto.SHA256, hash[:]))
+	case "ES256":
+		r, s := must2(ecdsa.Sign(rand.Reader, key.(*ecdsa.PrivateKey), hash[:]))
+		sig = make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+	case "EdDSA":
+		sig = ed25519.Sign(key.(ed25519.PrivateKey), []byte(signed))
+	}
+	return signed + "." + b64(sig)
+}
+
+func claims() map[string]any {
+	return map[string]any{
+		"sub":   "aragorn",
+		"admin": true,
+		"iss":   "gondor",
+		"aud":   []string{"api", "web"},
+		"exp":   time.Now().Add(time.Minute).Unix(),
+	}
+}
+
+func TestValidator_Algorithms(t *testing.T) {
+	secret := []byte("secret")
+	rsaKey := josh.Must(rsa.GenerateKey(rand.Reader, 2048))
+	ecKey := josh.Must(ecdsa.GenerateKey(elliptic.P256(), rand.Reader))
+	edPub, edKey := must2(ed25519.GenerateKey(rand.Reader))
+	keys := jwt.StaticKeys(
+		jwt.Key{ID: "hs", Key: secret},
+		jwt.Key{ID: "rs", Key: &rsaKey.PublicKey},
+		jwt.Key{ID: "es", Key: &ecKey.PublicKey},
+		jwt.Key{ID: "ed", Key: edPub},
+	)
+	v := jwt.Validator[Claims](jwt.Config{Keys: keys, Issuer: "gondor", Audience: "api"})
+	tokens := []string{
+		sign(t, "HS256", "hs", secret, claims()),
+		sign(t, "RS256", "rs", rsaKey, claims()),
+		sign(t, "ES256", "es", ecKey, claims()),
+		sign(t, "EdDSA", "ed", edKey, claims()),
+	}
+	for _, token := range tokens {
+		c, err := v(token)
+		eq(err, nil)
+		eq(c.Subject, "aragorn")
+		eq(c.Admin, true)
+	}
+
+	// Signed with a different key.
+	_, err := v(sign(t, "HS256", "hs", []byte("oh no"), claims()))
+	eq(errors.Is(err, jwt.ErrSignature), true)
+
+	// Key confusion: RSA public key used as HMAC secret.
+	_, err = v(sign(t, "HS256", "rs", []byte("whatever"), claims()))
+	eq(errors.Is(err, jwt.ErrSignature), true)
+
+	_, err = v(sign(t, "none", "", nil, claims()))
+	eq(errors.Is(err, jwt.ErrAlgorithm), true)
+
+	_, err = v("not.a-token")
+	eq(errors.Is(err, jwt.ErrMalformed), true)
+}
+
+func TestValidator_Claims(t *testing.T) {
+	secret := []byte("secret")
+	keys := jwt.StaticKeys(jwt.Key{Key: secret})
+	v := jwt.Validator[Claims](jwt.Config{
+		Keys:     keys,
+		Issuer:   "gondor",
+		Audience: "api",
+		Leeway:   time.Minute,
+	})
+	check := func(err error, patch func(map[string]any)) {
+		t.Helper()
+		c := claims()
+		patch(c)
+		_, actual := v(sign(t, "HS256", "", secret, c))
+		if !errors.Is(actual, err) {
+			t.Fatalf("expected %v, got %v", err, actual)
+		}
+	}
+	check(nil, func(c map[string]any) {})
+	check(nil, func(c map[string]any) { c["aud"] = "api" })
+	check(nil, func(c map[string]any) { c["exp"] = time.Now().Add(-30 * time.Second).Unix() })
+	check(jwt.ErrExpired, func(c map[string]any) { c["exp"] = time.Now().Add(-2 * time.Minute).Unix() })
+	check(jwt.ErrMissingExpiresAt, func(c map[string]any) { delete(c, "exp") })
+	check(nil, func(c map[string]any) { c["nbf"] = time.Now().Add(30 * time.Second).Unix() })
+	check(jwt.ErrNotYetValid, func(c map[string]any) { c["nbf"] = time.Now().Add(2 * time.Minute).Unix() })
+	check(jwt.ErrIssuer, func(c map[string]any) { c["iss"] = "mordor" })
+	check(jwt.ErrAudience, func(c map[string]any) { c["aud"] = "web" })
+}
+
+func TestJWKS(t *testing.T) {
+	key1 := josh.Must(ecdsa.GenerateKey(elliptic.P256(), rand.Reader))
+	key2 := josh.Must(ecdsa.GenerateKey(elliptic.P256(), rand.Reader))
+	jwk := func(kid string, key *ecdsa.PrivateKey) map[string]string {
+		return map[string]string{
+			"kty": "EC", "crv": "P-256", "kid": kid, "alg": "ES256", "use": "sig",
+			"x": b64(key.X.FillBytes(make([]byte, 32))),
+			"y": b64(key.Y.FillBytes(make([]byte, 32))),
+		}
+	}
+	current := []map[string]string{jwk("k1", key1)}
+	fetches := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches += 1
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": current})
+	}))
+	defer srv.Close()
+
+	keys := jwt.NewJWKS(srv.URL, time.Hour)
+	v := jwt.Validator[Claims](jwt.Config{Keys: keys})
+	_, err := v(sign(t, "ES256", "k1", key1, claims()))
+	eq(err, nil)
+	_, err = v(sign(t, "ES256", "k1", key1, claims()))
+	eq(err, nil)
+	eq(fetches, 1)
+
+	// The key is rotated.
+	current = append(current, jwk("k2", key2))
+	_, err = v(sign(t, "ES256", "k2", key2, claims()))
+	eq(err, nil)
+	eq(fetches, 2)
+}
+
+func TestJWKS_Unavailable(t *testing.T) {
+	key := josh.Must(ecdsa.GenerateKey(elliptic.P256(), rand.Reader))
+	jwk := map[string]string{
+		"kty": "EC", "crv": "P-256", "kid": "k1", "alg": "ES256", "use": "sig",
+		"x": b64(key.X.FillBytes(make([]byte, 32))),
+		"y": b64(key.Y.FillBytes(make([]byte, 32))),
+	}
+	fetches := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches += 1
+		if fetches > 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []any{jwk}})
+	}))
+	defer srv.Close()
+
+	// Zero TTL makes every call try to refresh the keys.
+	keys := jwt.NewJWKS(srv.URL, 0)
+	v := jwt.Validator[Claims](jwt.Config{Keys: keys})
+	for range 3 {
+		_, err := v(sign(t, "ES256", "k1", key, claims()))
+		eq(err, nil)
+	}
+	// The failed refresh is not retried right away, the cached keys are used.
+	eq(fetches, 2)
+}
@@ -0,0 +1,315 @@
+package jwt
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Key is a key for verifying token signatures.
+type Key struct {
+	// The key ID matched against the "kid" token header. Can be empty.
+	ID string
+
+	// The algorithm the key can be used with. If empty, any algorithm matching the key type.
+	Algorithm string
+
+	// The key itself. One of:
+	//
+	//   - []byte for HS256,
+	//   - *rsa.PublicKey for RS256,
+	//   - *ecdsa.PublicKey for ES256,
+	//   - ed25519.PublicKey for EdDSA.
+	Key any
+}
+
+// KeySet provides keys for verifying token signatures.
+type KeySet interface {
+	// Get the keys that may have signed the token with the given "kid" header.
+	Keys(kid string) ([]Key, error)
+}
+
+type staticKeys []Key
+
+// Create a [KeySet] from a fixed list of keys.
+func StaticKeys(keys ...Key) KeySet {
+	return staticKeys(keys)
+}
+
+// Keys implements [KeySet].
+func (s staticKeys) Keys(kid string) ([]Key, error) {
+	return filterKeys(s, kid), nil
+}
+
+// Get keys without ID and keys with the given ID.
+func filterKeys(keys []Key, kid string) []Key {
+	if kid == "" {
+		return keys
+	}
+	result := make([]Key, 0, len(keys))
+	for _, key := range keys {
+		if key.ID == "" || key.ID == kid {
+			result = append(result, key)
+		}
+	}
+	return result
+}
+
+// How often [JWKS] can be refreshed because of an unknown key ID
+// and how long to wait before retrying a failed refresh.
+const minRefreshInterval = 10 * time.Second
+
+// JWKS is a [KeySet] loaded from a JSON Web Key Set.
+//
+// The keys are cached for the TTL. If a token has an unknown key ID,
+// the keys are reloaded (at most once per 10 seconds) to pick up rotated keys.
+// If loading fails, the cached keys are used and the next attempt
+// is made after 10 seconds. Only one request loads the keys at a time,
+// concurrent requests use the cached keys or wait for it.
+//
+// Must be constructed using [NewJWKS].
+//
+// https://datatracker.ietf.org/doc/html/rfc7517
+type JWKS struct {
+	// The HTTP client used to fetch the keys. Defaults to [http.DefaultClient].
+	Client *http.Client
+
+	source string
+	ttl    time.Duration
+
+	mu       sync.Mutex
+	keys     []Key
+	err      error
+	fetched  time.Time
+	failed   time.Time
+	lastMiss time.Time
+	// Closed when the refresh in progress finishes. Nil if there is none.
+	inflight chan struct{}
+}
+
+// Create a [JWKS] that loads keys from the given file path or "http(s)://" URL.
+func NewJWKS(source string, ttl time.Duration) *JWKS {
+	return &JWKS{source: source, ttl: ttl}
+}
+
+// Keys implements [KeySet].
+func (j *JWKS) Keys(kid string) ([]Key, error) {
+	j.mu.Lock()
+	now := time.Now()
+	stale := now.Sub(j.fetched) >= j.ttl
+	missing := kid != "" && !hasKey(j.keys, kid)
+	if !stale && missing && now.Sub(j.lastMiss) >= minRefreshInterval {
+		// The keys might have been rotated.
+		j.lastMiss = now
+		stale = true
+	}
+	if now.Sub(j.failed) < minRefreshInterval {
+		// The last attempt failed recently, don't hammer the source.
+		stale = false
+	}
+	switch {
+	case !stale:
+	case j.inflight == nil:
+		done := make(chan struct{})
+		j.inflight = done
+		j.mu.Unlock()
+		j.refresh(done)
+		j.mu.Lock()
+	case j.keys != nil && !missing:
+		// Another request is refreshing the keys, the cached ones will do meanwhile.
+	default:
+		done := j.inflight
+		j.mu.Unlock()
+		<-done
+		j.mu.Lock()
+	}
+	defer j.mu.Unlock()
+	if j.keys == nil {
+		return nil, j.err
+	}
+	return filterKeys(j.keys, kid), nil
+}
+
+func hasKey(keys []Key, kid string) bool {
+	for _, key := range keys {
+		if key.ID == kid {
+			return true
+		}
+	}
+	return false
+}
+
+// Load the keys from the source and close the channel when done.
+//
+// Must be called without the lock held, so that other requests aren't blocked.
+func (j *JWKS) refresh(done chan struct{}) {
+	keys, err := j.fetch()
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err != nil {
+		j.err = err
+		j.failed = time.Now()
+	} else {
+		j.keys = keys
+		j.err = nil
+		j.fetched = time.Now()
+	}
+	j.inflight = nil
+	close(done)
+}
+
+func (j *JWKS) fetch() ([]Key, error) {
+	raw, err := j.load()
+	if err != nil {
+		return nil, fmt.Errorf("load JWKS: %w", err)
+	}
+	keys, err := ParseJWKS(raw)
+	if err != nil {
+		return nil, fmt.Errorf("parse JWKS: %w", err)
+	}
+	return keys, nil
+}
+
+func (j *JWKS) load() ([]byte, error) {
+	if !strings.HasPrefix(j.source, "http://") && !strings.HasPrefix(j.source, "https://") {
+		return os.ReadFile(j.source)
+	}
+	client := j.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, j.source, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 1024*1024))
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+// Parse a JSON Web Key Set.
+//
+// Keys of unsupported types and keys not intended for signatures are skipped.
+func ParseJWKS(raw []byte) ([]Key, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	err := json.Unmarshal(raw, &set)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]Key, 0, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := parseJWK(k)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", k.Kid, err)
+		}
+		if key != nil {
+			keys = append(keys, Key{ID: k.Kid, Algorithm: k.Alg, Key: key})
+		}
+	}
+	return keys, nil
+}
+
+// Convert JWK into a Go key. Returns nil for unsupported key types.
+func parseJWK(k jwk) (any, error) {
+	switch k.Kty {
+	case "oct":
+		return b64(k.K)
+	case "RSA":
+		n, err := b64(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := b64(k.E)
+		if err != nil {
+			return nil, err
+		}
+		exp := new(big.Int).SetBytes(e)
+		if !exp.IsInt64() || exp.Int64() > 1<<31-1 || exp.Int64() < 3 {
+			return nil, errors.New("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exp.Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, nil
+		}
+		x, err := b64(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := b64(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != 32 || len(y) != 32 {
+			return nil, errors.New("invalid EC point size")
+		}
+		// Validate that the point is on the curve.
+		point := append(append([]byte{4}, x...), y...)
+		_, err = ecdh.P256().NewPublicKey(point)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, nil
+		}
+		x, err := b64(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key size")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, nil
+}
+
+func b64(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
@@ -0,0 +1,202 @@
+package josh
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// Runner runs an [http.Server] and gracefully shuts it down.
+//
+// Must be constructed using [NewRunner]. A runner can be run only once.
+//
+//	s := josh.NewServer(":8080")
+//	runner := josh.NewRunner(s)
+//	runner.OnShutdown(func(ctx context.Context) error {
+//		return db.Close()
+//	})
+//	err := runner.Run(context.Background())
+type Runner struct {
+	// The server to run.
+	Server *http.Server
+
+	// How long to wait for in-flight requests to finish
+	// and, separately, for the shutdown hooks to run.
+	ShutdownTimeout time.Duration
+
+	// Signals that trigger the shutdown. If empty, signals are not handled.
+	Signals []os.Signal
+
+	mu       sync.Mutex
+	hooks    []func(context.Context) error
+	ready    atomic.Bool
+	started  atomic.Bool
+	draining chan struct{}
+}
+
+// A channel closed when the server starts shutting down.
+type shutdownSignal <-chan struct{}
+
+// Create a [Runner] for the server with default settings.
+//
+// The default shutdown timeout is 10 seconds
+// and the shutdown is triggered by SIGINT and SIGTERM.
+func NewRunner(s *http.Server) *Runner {
+	return &Runner{
+		Server:          s,
+		ShutdownTimeout: 10 * time.Second,
+		Signals:         []os.Signal{os.Interrupt, syscall.SIGTERM},
+		draining:        make(chan struct{}),
+	}
+}
+
+// Register a function to call when the server is stopped.
+//
+// The hooks are called after all in-flight requests are finished,
+// in reverse order of registration, like defer.
+func (r *Runner) OnShutdown(f func(context.Context) error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hooks = append(r.hooks, f)
+}
+
+// Check if the server is ready to accept requests.
+//
+// It becomes true when the server starts listening
+// and false as soon as the shutdown starts.
+func (r *Runner) Ready() bool {
+	return r.ready.Load()
+}
+
+// Listen on the server address and serve requests until shutdown.
+//
+// See [Runner.Serve].
+func (r *Runner) Run(ctx context.Context) error {
+	err := r.start()
+	if err != nil {
+		return err
+	}
+	addr := r.Server.Addr
+	if addr == "" {
+		addr = ":http"
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return r.serve(ctx, ln)
+}
+
+// Serve requests from the listener until shutdown.
+//
+// The shutdown starts when the context is canceled, one of the signals is received,
+// or the server fails. Then the runner:
+//
+//  1. marks the server as not ready (see [Runner.Ready]),
+//  2. notifies long-running handlers (see [ShuttingDown]),
+//  3. waits for in-flight requests to finish (see [http.Server.Shutdown]),
+//  4. closes all remaining connections if they didn't finish in time,
+//  5. calls the shutdown hooks (see [Runner.OnShutdown]).
+//
+// All errors that occurred are combined using [errors.Join].
+// If the runner was not created by [NewRunner] or was already run,
+// an error is returned right away.
+func (r *Runner) Serve(ctx context.Context, ln net.Listener) error {
+	err := r.start()
+	if err != nil {
+		return err
+	}
+	return r.serve(ctx, ln)
+}
+
+// Check that the runner can be run and mark it as started.
+func (r *Runner) start() error {
+	if r.draining == nil {
+		return errors.New("josh: Runner must be created using NewRunner")
+	}
+	if !r.started.CompareAndSwap(false, true) {
+		return errors.New("josh: Runner can be run only once")
+	}
+	return nil
+}
+
+func (r *Runner) serve(ctx context.Context, ln net.Listener) error {
+	baseContext := r.Server.BaseContext
+	r.Server.BaseContext = func(l net.Listener) context.Context {
+		ctx := context.Background()
+		if baseContext != nil {
+			ctx = baseContext(l)
+		}
+		ctx, _ = CWithSingleton(ctx, shutdownSignal(r.draining))
+		return ctx
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		var err error
+		if r.Server.TLSConfig != nil {
+			err = r.Server.ServeTLS(ln, "", "")
+		} else {
+			err = r.Server.Serve(ln)
+		}
+		if errors.Is(err, http.ErrServerClosed) {
+			err = nil
+		}
+		serveErr <- err
+	}()
+	r.ready.Store(true)
+
+	// Without signals, NotifyContext would relay all of them, including SIGURG used by the runtime.
+	if len(r.Signals) != 0 {
+		var stop context.CancelFunc
+		ctx, stop = signal.NotifyContext(ctx, r.Signals...)
+		defer stop()
+	}
+	var errs []error
+	select {
+	case err := <-serveErr:
+		// Put the error back to not block on reading it later.
+		serveErr <- err
+	case <-ctx.Done():
+	}
+
+	r.ready.Store(false)
+	close(r.draining)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), r.ShutdownTimeout)
+	defer cancel()
+	err := r.Server.Shutdown(shutdownCtx)
+	if err != nil {
+		errs = append(errs, err)
+		errs = append(errs, r.Server.Close())
+	}
+	errs = append(errs, <-serveErr)
+
+	r.mu.Lock()
+	hooks := slices.Clone(r.hooks)
+	r.mu.Unlock()
+	hooksCtx, cancel := context.WithTimeout(context.Background(), r.ShutdownTimeout)
+	defer cancel()
+	for _, hook := range slices.Backward(hooks) {
+		errs = append(errs, hook(hooksCtx))
+	}
+	return errors.Join(errs...)
+}
+
+// Get a channel that is closed when the server starts shutting down.
+//
+// Long-running handlers, like streams, should stop when it happens,
+// otherwise the server will forcefully close the connection when
+// [Runner.ShutdownTimeout] passes. If the server is not run by [Runner],
+// the returned channel is never closed.
+func ShuttingDown(r Req) <-chan struct{} {
+	ch, _ := GetSingleton[shutdownSignal](r)
+	return ch
+}
@@ -0,0 +1,81 @@
+package josh_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/orsinium-labs/josh"
+)
+
+func TestRunner(t *testing.T) {
+	started := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", josh.Wrap(func(r josh.Req) josh.Resp {
+		close(started)
+		<-josh.ShuttingDown(r)
+		return josh.Ok("bye")
+	}))
+	s := josh.NewServer("")
+	s.Handler = mux
+	runner := josh.NewRunner(s)
+	runner.ShutdownTimeout = time.Second
+	var calls []int
+	runner.OnShutdown(func(context.Context) error {
+		calls = append(calls, 1)
+		return nil
+	})
+	runner.OnShutdown(func(context.Context) error {
+		calls = append(calls, 2)
+		return nil
+	})
+
+	ln := must(net.Listen("tcp", "127.0.0.1:0"))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- runner.Serve(ctx, ln)
+	}()
+
+	type result struct {
+		status int
+		body   string
+	}
+	resps := make(chan result)
+	go func() {
+		resp := must(http.Get("http://" + ln.Addr().String()))
+		body := must(io.ReadAll(resp.Body))
+		_ = resp.Body.Close()
+		resps <- result{resp.StatusCode, string(body)}
+	}()
+	<-started
+	eq(runner.Ready(), true)
+	cancel()
+
+	// The in-flight request is finished before the server stops.
+	res := <-resps
+	eq(res.status, 200)
+	eq(res.body, `{"data":"bye"}`+"\n")
+	eq(<-done, nil)
+	eq(runner.Ready(), false)
+	eq(len(calls), 2)
+	eq(calls[0], 2)
+	eq(calls[1], 1)
+}
+
+func TestRunner_Invalid(t *testing.T) {
+	ln := must(net.Listen("tcp", "127.0.0.1:0"))
+	defer ln.Close()
+	runner := &josh.Runner{Server: josh.NewServer("")}
+	eq(runner.Serve(context.Background(), ln) != nil, true)
+
+	runner = josh.NewRunner(josh.NewServer(""))
+	runner.Signals = nil
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	eq(runner.Serve(ctx, ln), nil)
+	eq(runner.Serve(ctx, ln) != nil, true)
+}
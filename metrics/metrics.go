@@ -0,0 +1,134 @@
+// Package metrics collects RED (rate, errors, duration) metrics for josh services
+// and exposes them in the Prometheus text format without external dependencies.
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/orsinium-labs/josh"
+	"github.com/orsinium-labs/josh/sse"
+	"github.com/orsinium-labs/josh/statuses"
+)
+
+// Collect request metrics for the handler.
+//
+// The following metrics are registered in the registry:
+//
+//   - josh_http_requests_total: counter of finished requests.
+//   - josh_http_request_duration_seconds: histogram of request latencies.
+//   - josh_http_requests_in_flight: gauge of requests being handled.
+//
+// The counter and the histogram are labeled by the request method,
+// the route pattern ([http.Request.Pattern]), and the status class (like "2xx").
+//
+// The same registry can be used for multiple handlers.
+func Measure(reg *Registry, h josh.Handler) josh.Handler {
+	labels := []string{"method", "pattern", "status"}
+	requests := reg.Counter(
+		"josh_http_requests_total",
+		"The total number of finished HTTP requests.",
+		labels...,
+	)
+	duration := reg.Histogram(
+		"josh_http_request_duration_seconds",
+		"The HTTP request latencies in seconds.",
+		nil,
+		labels...,
+	)
+	inFlight := reg.Gauge(
+		"josh_http_requests_in_flight",
+		"The number of HTTP requests being handled.",
+	)
+	return func(r josh.Req) josh.Resp {
+		inFlight.Inc()
+		defer inFlight.Dec()
+		start := time.Now()
+		var w *statusWriter
+		orig, err := josh.GetSingleton[http.ResponseWriter](r)
+		if err == nil {
+			w = &statusWriter{ResponseWriter: orig}
+			r = josh.ReplaceSingleton[http.ResponseWriter](r, w)
+		}
+
+		resp := h(r)
+		status := responseStatus(resp, w)
+		class := strconv.Itoa(int(status)/100) + "xx"
+		requests.Inc(r.Method, r.Pattern, class)
+		duration.Observe(time.Since(start).Seconds(), r.Method, r.Pattern, class)
+		return resp
+	}
+}
+
+// The status code that will be sent to the client.
+func responseStatus(resp josh.Resp, w *statusWriter) statuses.Status {
+	switch {
+	case resp.Status != 0:
+		return resp.Status
+	case resp.Errors != nil:
+		return statuses.BadRequest
+	case resp.Data != nil:
+		return statuses.OK
+	case w != nil && w.status != 0:
+		return w.status
+	}
+	return statuses.OK
+}
+
+// Count requests handled by the [josh.Dispatcher].
+//
+// Registers josh_dispatcher_requests_total counter labeled by the request type.
+func InstrumentDispatcher(reg *Registry, d *josh.Dispatcher) {
+	requests := reg.Counter(
+		"josh_dispatcher_requests_total",
+		"The total number of requests routed by josh.Dispatcher.",
+		"type",
+	)
+	d.OnDispatch(func(_ context.Context, t string) {
+		requests.Inc(t)
+	})
+}
+
+// Report the number of open [sse.Stream] connections.
+//
+// Registers josh_sse_open_streams gauge.
+func InstrumentSSE(reg *Registry) {
+	reg.GaugeFunc(
+		"josh_sse_open_streams",
+		"The number of open server-sent events streams.",
+		func() float64 {
+			return float64(sse.OpenStreams())
+		},
+	)
+}
+
+// A wrapper for [http.ResponseWriter] recording the status code.
+type statusWriter struct {
+	http.ResponseWriter
+	status statuses.Status
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = statuses.Status(status)
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Flush implements [http.Flusher], required for streaming responses.
+func (w *statusWriter) Flush() {
+	if w.status == 0 {
+		w.status = statuses.OK
+	}
+	flusher, ok := w.ResponseWriter.(http.Flusher)
+	if ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap is used by [http.ResponseController] to access the original writer.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,116 @@
+package metrics_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/orsinium-labs/josh"
+	"github.com/orsinium-labs/josh/metrics"
+)
+
+func eq[T comparable](a, b T) {
+	if a != b {
+		panic(fmt.Sprintf("%v != %v", a, b))
+	}
+}
+
+func contains(t *testing.T, s, sub string) {
+	t.Helper()
+	if !strings.Contains(s, sub) {
+		t.Fatalf("%q not found in:\n%s", sub, s)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	reg := metrics.NewRegistry()
+	c := reg.Counter("hits_total", "Number of hits.", "path")
+	c.Inc("/a")
+	c.Add(2, `/b"`)
+	eq(reg.Counter("hits_total", "Number of hits.", "path"), c)
+	g := reg.Gauge("temp", "Temperature.\nIn C.")
+	g.Set(13.5)
+	h := reg.Histogram("size", "Size.", []float64{10, 1})
+	h.Observe(5)
+	h.Observe(20)
+
+	var buf bytes.Buffer
+	eq(reg.Write(&buf), nil)
+	exp := `# HELP hits_total Number of hits.
+# TYPE hits_total counter
+hits_total{path="/a"} 1
+hits_total{path="/b\""} 2
+# HELP temp Temperature.\nIn C.
+# TYPE temp gauge
+temp 13.5
+# HELP size Size.
+# TYPE size histogram
+size_bucket{le="1"} 0
+size_bucket{le="10"} 1
+size_bucket{le="+Inf"} 2
+size_sum 25
+size_count 2
+`
+	eq(buf.String(), exp)
+}
+
+func TestRegistry_Mismatch(t *testing.T) {
+	mustPanic := func(f func()) {
+		defer func() {
+			eq(recover() != nil, true)
+		}()
+		f()
+	}
+	reg := metrics.NewRegistry()
+	reg.Counter("hits_total", "Number of hits.", "path")
+	reg.Histogram("size", "Size.", nil)
+	mustPanic(func() { reg.Counter("hits_total", "Number of hits.", "method") })
+	mustPanic(func() { reg.Counter("hits_total", "Number of hits.") })
+	mustPanic(func() { reg.Gauge("hits_total", "Number of hits.", "path") })
+	mustPanic(func() { reg.Histogram("size", "Size.", nil, "path") })
+}
+
+func TestMeasure(t *testing.T) {
+	reg := metrics.NewRegistry()
+	hf := func(r josh.Req) josh.Resp {
+		if r.URL.Path == "/missing" {
+			return josh.NotFound(josh.Error{Detail: "oh no"})
+		}
+		return josh.Ok("hi")
+	}
+	h := josh.Wrap(metrics.Measure(reg, hf))
+	for _, path := range []string{"/", "/", "/missing"} {
+		req := httptest.NewRequest("GET", "http://example.com"+path, nil)
+		h(httptest.NewRecorder(), req)
+	}
+
+	req := httptest.NewRequest("GET", "http://example.com/metrics", nil)
+	w := httptest.NewRecorder()
+	reg.Handler().ServeHTTP(w, req)
+	eq(w.Code, 200)
+	contains(t, w.Header().Get("Content-Type"), "text/plain; version=0.0.4")
+	body := string(josh.Must(io.ReadAll(w.Result().Body)))
+	contains(t, body, `josh_http_requests_total{method="GET",pattern="",status="2xx"} 2`)
+	contains(t, body, `josh_http_requests_total{method="GET",pattern="",status="4xx"} 1`)
+	contains(t, body, `josh_http_request_duration_seconds_count{method="GET",pattern="",status="2xx"} 2`)
+	contains(t, body, "josh_http_requests_in_flight 0")
+}
+
+func TestInstrumentDispatcher(t *testing.T) {
+	reg := metrics.NewRegistry()
+	d := josh.NewDispatcher()
+	josh.Register(&d, "greet", func(ctx context.Context, name string) josh.Resp {
+		return josh.Ok("hello " + name)
+	})
+	metrics.InstrumentDispatcher(reg, &d)
+	resp := d.Read(context.Background(), strings.NewReader(`{"data":{"type":"greet","attributes":"bob"}}`))
+	eq(resp.Status, 200)
+
+	var buf bytes.Buffer
+	eq(reg.Write(&buf), nil)
+	contains(t, buf.String(), `josh_dispatcher_requests_total{type="greet"} 1`)
+}
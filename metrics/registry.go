@@ -0,0 +1,345 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Default buckets for latency histograms, in seconds.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Registry is a collection of metrics that can be exposed using [Registry.Handler].
+//
+// Metrics are created by the registry methods. Calling a method again with the same
+// metric name returns the already registered metric. If the metric was registered
+// with a different type or different label names, the method panics.
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+	byName  map[string]metric
+}
+
+type metric interface {
+	write(w *bufio.Writer)
+	labelNames() []string
+}
+
+// Create a new empty [Registry].
+func NewRegistry() *Registry {
+	return &Registry{byName: make(map[string]metric)}
+}
+
+// Get or create a metric with the given name.
+func register[M metric](reg *Registry, name string, labels []string, create func() M) M {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	old, found := reg.byName[name]
+	if found {
+		m, ok := old.(M)
+		if !ok {
+			panic("metric " + name + " is already registered with a different type")
+		}
+		if !slices.Equal(m.labelNames(), labels) {
+			panic(fmt.Sprintf("metric %s is already registered with labels %v, got %v", name, m.labelNames(), labels))
+		}
+		return m
+	}
+	m := create()
+	reg.byName[name] = m
+	reg.metrics = append(reg.metrics, m)
+	return m
+}
+
+// Get or create a counter.
+//
+// A counter is a value that only goes up, like the number of handled requests.
+func (reg *Registry) Counter(name, help string, labels ...string) *Counter {
+	return register(reg, name, labels, func() *Counter {
+		return &Counter{family: newFamily[float64](name, help, "counter", labels)}
+	})
+}
+
+// Get or create a gauge.
+//
+// A gauge is a value that can go up and down, like the number of in-flight requests.
+func (reg *Registry) Gauge(name, help string, labels ...string) *Gauge {
+	return register(reg, name, labels, func() *Gauge {
+		return &Gauge{family: newFamily[float64](name, help, "gauge", labels)}
+	})
+}
+
+// Get or create a gauge without labels which value is produced by the function on each scrape.
+func (reg *Registry) GaugeFunc(name, help string, f func() float64) {
+	register(reg, name, nil, func() *gaugeFunc {
+		return &gaugeFunc{name: name, help: help, f: f}
+	})
+}
+
+// Get or create a histogram.
+//
+// A histogram counts observations, like request durations, in the given buckets.
+// If buckets is nil, [DefaultBuckets] is used.
+func (reg *Registry) Histogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	buckets = slices.Clone(buckets)
+	slices.Sort(buckets)
+	return register(reg, name, labels, func() *Histogram {
+		return &Histogram{
+			family:  newFamily[*histogramSeries](name, help, "histogram", labels),
+			buckets: buckets,
+		}
+	})
+}
+
+// Handler serving all metrics in the Prometheus text exposition format.
+//
+// https://prometheus.io/docs/instrumenting/exposition_formats/#text-based-format
+func (reg *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_ = reg.Write(w)
+	})
+}
+
+// Write all metrics in the Prometheus text exposition format.
+func (reg *Registry) Write(w io.Writer) error {
+	reg.mu.Lock()
+	metrics := slices.Clone(reg.metrics)
+	reg.mu.Unlock()
+	buf := bufio.NewWriter(w)
+	for _, m := range metrics {
+		m.write(buf)
+	}
+	return buf.Flush()
+}
+
+// A metric with a value for each combination of label values.
+type family[V any] struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+	mu     sync.Mutex
+	series map[string]*series[V]
+}
+
+type series[V any] struct {
+	labels []string
+	value  V
+}
+
+func newFamily[V any](name, help, kind string, labels []string) family[V] {
+	return family[V]{
+		name:   name,
+		help:   help,
+		kind:   kind,
+		labels: labels,
+		series: make(map[string]*series[V]),
+	}
+}
+
+func (f *family[V]) labelNames() []string {
+	return f.labels
+}
+
+// Get or create the series for the given label values. Must be called with the lock held.
+func (f *family[V]) get(values []string) *series[V] {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("metric %s expects %d label values, got %d", f.name, len(f.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	s, found := f.series[key]
+	if !found {
+		s = &series[V]{labels: slices.Clone(values)}
+		f.series[key] = s
+	}
+	return s
+}
+
+// Write the HELP and TYPE lines and return all series sorted by labels.
+//
+// Must be called with the lock held.
+func (f *family[V]) header(w *bufio.Writer) []*series[V] {
+	fmt.Fprintf(w, "# HELP %s %s\n", f.name, escapeHelp(f.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", f.name, f.kind)
+	result := make([]*series[V], 0, len(f.series))
+	for _, s := range f.series {
+		result = append(result, s)
+	}
+	slices.SortFunc(result, func(a, b *series[V]) int {
+		return slices.Compare(a.labels, b.labels)
+	})
+	return result
+}
+
+// Format labels as {name="value",...}. Extra label is appended if not empty.
+func (f *family[V]) formatLabels(values []string, extra string) string {
+	if len(values) == 0 && extra == "" {
+		return ""
+	}
+	parts := make([]string, 0, len(values)+1)
+	for i, name := range f.labels {
+		parts = append(parts, name+`="`+escapeLabel(values[i])+`"`)
+	}
+	if extra != "" {
+		parts = append(parts, extra)
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+// Counter is a metric that only goes up.
+type Counter struct {
+	family[float64]
+}
+
+// Increment the counter for the given label values by one.
+func (c *Counter) Inc(labels ...string) {
+	c.Add(1, labels...)
+}
+
+// Increase the counter for the given label values by the given non-negative value.
+func (c *Counter) Add(v float64, labels ...string) {
+	if v < 0 {
+		panic("counter cannot decrease")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.get(labels).value += v
+}
+
+func (c *Counter) write(w *bufio.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, s := range c.header(w) {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, c.formatLabels(s.labels, ""), formatFloat(s.value))
+	}
+}
+
+// Gauge is a metric that can go up and down.
+type Gauge struct {
+	family[float64]
+}
+
+// Set the gauge for the given label values to the given value.
+func (g *Gauge) Set(v float64, labels ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.get(labels).value = v
+}
+
+// Add the given value (can be negative) to the gauge for the given label values.
+func (g *Gauge) Add(v float64, labels ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.get(labels).value += v
+}
+
+// Increment the gauge for the given label values by one.
+func (g *Gauge) Inc(labels ...string) {
+	g.Add(1, labels...)
+}
+
+// Decrement the gauge for the given label values by one.
+func (g *Gauge) Dec(labels ...string) {
+	g.Add(-1, labels...)
+}
+
+func (g *Gauge) write(w *bufio.Writer) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, s := range g.header(w) {
+		fmt.Fprintf(w, "%s%s %s\n", g.name, g.formatLabels(s.labels, ""), formatFloat(s.value))
+	}
+}
+
+type gaugeFunc struct {
+	name string
+	help string
+	f    func() float64
+}
+
+func (g *gaugeFunc) labelNames() []string {
+	return nil
+}
+
+func (g *gaugeFunc) write(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", g.name, escapeHelp(g.help))
+	fmt.Fprintf(w, "# TYPE %s gauge\n", g.name)
+	fmt.Fprintf(w, "%s %s\n", g.name, formatFloat(g.f()))
+}
+
+// Histogram is a metric counting observations in buckets.
+type Histogram struct {
+	family[*histogramSeries]
+	buckets []float64
+}
+
+type histogramSeries struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// Record the observed value for the given label values.
+func (h *Histogram) Observe(v float64, labels ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := h.get(labels)
+	if s.value == nil {
+		s.value = &histogramSeries{counts: make([]uint64, len(h.buckets))}
+	}
+	for i, upper := range h.buckets {
+		if v <= upper {
+			s.value.counts[i]++
+		}
+	}
+	s.value.sum += v
+	s.value.count++
+}
+
+func (h *Histogram) write(w *bufio.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, s := range h.header(w) {
+		for i, upper := range h.buckets {
+			le := `le="` + formatFloat(upper) + `"`
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.formatLabels(s.labels, le), s.value.counts[i])
+		}
+		labels := h.formatLabels(s.labels, "")
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.formatLabels(s.labels, `le="+Inf"`), s.value.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, labels, formatFloat(s.value.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, labels, s.value.count)
+	}
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+var labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
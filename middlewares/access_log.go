@@ -0,0 +1,154 @@
+package middlewares
+
+import (
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/orsinium-labs/josh"
+	"github.com/orsinium-labs/josh/statuses"
+)
+
+// Log one record for each finished request.
+//
+// The record includes the response status code, the number of bytes
+// written into the response body, the latency, and the user set by
+// an auth middleware ([Auth], [APIKey], [BasicAuth], [HMAC]), if any.
+// The logger is taken from the request context (see [WithLogger]).
+// If there is none, the default logger is used and the request method
+// and pattern are added into the record.
+//
+// Server errors are logged at the error level, client errors at the warning level,
+// and everything else at the info level.
+//
+// The "sample" is the fraction of successful (2xx) responses that are logged,
+// from 0 to 1. Use 1 to log all of them. Other responses are always logged.
+//
+// The record is written after the response is sent by [josh.Wrap].
+// Place the middleware right inside of [WithLogger] and outside of everything else,
+// so that it sees all requests, including the ones rejected by auth middlewares:
+//
+//	h = middlewares.WithLogger(logger, middlewares.AccessLog(1, middlewares.Auth(v, h)))
+func AccessLog(sample float64, h josh.Handler) josh.Handler {
+	return func(r josh.Req) josh.Resp {
+		start := time.Now()
+		w := &countingWriter{
+			ResponseWriter: josh.Must(josh.GetSingleton[http.ResponseWriter](r)),
+		}
+		slot := &userSlot{}
+		var resp josh.Resp
+		inner := josh.Must(josh.Intercept(r, w, func() {
+			logRequest(r, sample, start, w, slot, resp)
+		}))
+		inner = josh.ReplaceSingleton(inner, slot)
+		resp = h(inner)
+		return resp
+	}
+}
+
+// Write the access log record for the finished request.
+func logRequest(r josh.Req, sample float64, start time.Time, w *countingWriter, slot *userSlot, resp josh.Resp) {
+	canceled := josh.Canceled(r)
+	status := w.status
+	switch {
+	case status != 0:
+	case resp.Status != 0:
+		status = resp.Status
+	case resp.Errors != nil:
+		status = statuses.BadRequest
+	default:
+		// The status that net/http sends if nothing is written.
+		status = statuses.OK
+	}
+	if status.IsSuccess() && sample < 1 && rand.Float64() >= sample {
+		return
+	}
+	level := slog.LevelInfo
+	if status.IsServerError() {
+		level = slog.LevelError
+	} else if status.IsClientError() {
+		level = slog.LevelWarn
+	}
+	attrs := []slog.Attr{
+		slog.Int("status", int(status)),
+		slog.Int64("bytes", w.written),
+		slog.Duration("latency", time.Since(start)),
+	}
+	logger, err := josh.GetSingleton[*slog.Logger](r)
+	if err != nil {
+		logger = slog.Default()
+		attrs = append(attrs,
+			slog.String("method", r.Method),
+			slog.String("pattern", r.Pattern),
+		)
+	}
+	user, ok := slot.get()
+	if ok {
+		attrs = append(attrs, slog.Any("user", user))
+	}
+	if canceled {
+		attrs = append(attrs, slog.Bool("canceled", true))
+	}
+	logger.LogAttrs(r.Context(), level, "request finished", attrs...)
+}
+
+// A place for auth middlewares to put the user, so that [AccessLog] can see it.
+//
+// The user is set by inner middlewares, possibly from another goroutine
+// (see [Timeout]), so it's protected by a mutex.
+type userSlot struct {
+	mu   sync.Mutex
+	user any
+	set  bool
+}
+
+func (s *userSlot) put(user any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.user = user
+	s.set = true
+}
+
+func (s *userSlot) get() (any, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.user, s.set
+}
+
+// A wrapper for [http.ResponseWriter] recording the status code and the body size.
+type countingWriter struct {
+	http.ResponseWriter
+	status  statuses.Status
+	written int64
+}
+
+func (w *countingWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = statuses.Status(status)
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *countingWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.written += int64(n)
+	return n, err
+}
+
+// Flush implements [http.Flusher], required for streaming responses.
+func (w *countingWriter) Flush() {
+	if w.status == 0 {
+		w.status = statuses.OK
+	}
+	flusher, ok := w.ResponseWriter.(http.Flusher)
+	if ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap is used by [http.ResponseController] to access the original writer.
+func (w *countingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,104 @@
+package middlewares_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/orsinium-labs/josh"
+	"github.com/orsinium-labs/josh/middlewares"
+)
+
+func TestAccessLog(t *testing.T) {
+	type User string
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	hf := func(r josh.Req) josh.Resp {
+		return josh.Ok("hi")
+	}
+	v := func(token string) (User, error) {
+		if token == "secret" {
+			return "aragorn", nil
+		}
+		return "", errors.New("bad token")
+	}
+	h := middlewares.Auth(v, hf)
+	h = middlewares.AccessLog(1, h)
+	hh := josh.Wrap(middlewares.WithLogger(logger, h))
+
+	req := httptest.NewRequest("GET", "http://example.com/foo", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	hh(w, req)
+	eq(w.Code, 200)
+	eq(w.Body.String(), `{"data":"hi"}`+"\n")
+	line := buf.String()
+	eq(strings.Contains(line, "level=INFO"), true)
+	eq(strings.Contains(line, "status=200"), true)
+	eq(strings.Contains(line, "bytes=14"), true)
+	eq(strings.Contains(line, "user=aragorn"), true)
+	eq(strings.Contains(line, "method=GET"), true)
+
+	// Requests rejected by Auth are logged too.
+	buf.Reset()
+	req = httptest.NewRequest("GET", "http://example.com/foo", nil)
+	w = httptest.NewRecorder()
+	hh(w, req)
+	eq(w.Code, 401)
+	line = buf.String()
+	eq(strings.Contains(line, "level=WARN"), true)
+	eq(strings.Contains(line, "status=401"), true)
+	eq(strings.Contains(line, fmt.Sprintf("bytes=%d", w.Body.Len())), true)
+	eq(strings.Contains(line, "user="), false)
+}
+
+func TestAccessLog_Resp(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	hf := func(r josh.Req) josh.Resp {
+		return josh.NotFound(josh.Error{Detail: "not found"})
+	}
+	// The response is passed to outer middlewares unchanged.
+	h := middlewares.WithRequestID(middlewares.AccessLog(1, hf))
+	hh := josh.Wrap(middlewares.WithLogger(logger, h))
+
+	req := httptest.NewRequest("GET", "http://example.com/foo", nil)
+	req.Header.Set("X-Request-ID", "req-1")
+	w := httptest.NewRecorder()
+	hh(w, req)
+	eq(w.Code, 404)
+	eq(strings.Contains(w.Body.String(), `"id":"req-1"`), true)
+	line := buf.String()
+	// The bytes that were actually sent, including the changes of outer middlewares.
+	eq(strings.Contains(line, fmt.Sprintf("bytes=%d", w.Body.Len())), true)
+	eq(strings.Contains(line, "level=WARN"), true)
+	eq(strings.Contains(line, "status=404"), true)
+	eq(strings.Contains(line, "user="), false)
+}
+
+func TestAccessLog_Sample(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	hf := func(r josh.Req) josh.Resp {
+		if r.URL.Path == "/fail" {
+			panic("oh no")
+		}
+		return josh.NoContent()
+	}
+	h := middlewares.AccessLog(0, middlewares.Recover(hf))
+	hh := josh.Wrap(middlewares.WithLogger(logger, h))
+
+	w := httptest.NewRecorder()
+	hh(w, httptest.NewRequest("GET", "http://example.com/ok", nil))
+	eq(w.Code, 204)
+	eq(buf.String(), "")
+
+	w = httptest.NewRecorder()
+	hh(w, httptest.NewRequest("GET", "http://example.com/fail", nil))
+	eq(w.Code, 500)
+	eq(strings.Contains(buf.String(), "level=ERROR msg=\"request finished\""), true)
+}
@@ -0,0 +1,89 @@
+package middlewares
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+
+	"github.com/orsinium-labs/josh"
+	"github.com/orsinium-labs/josh/headers"
+	"github.com/orsinium-labs/josh/statuses"
+)
+
+// The auth scheme in the "WWW-Authenticate" header of [APIKey] responses.
+//
+// There is no registered scheme for API keys, so this one is conventional.
+const apiKeyScheme = "APIKey"
+
+// Authenticate the request using an API key before proceeding to the handler.
+//
+// The key is taken from the given request header (typically, "X-API-Key").
+// If the header is not provided and the query parameter name is not empty,
+// the key is taken from that query parameter. Keep in mind that query parameters
+// end up in access logs and browser history, so prefer headers.
+//
+// The validator works the same way as for [Auth]. The returned user
+// is added into the request context using [josh.WithSingleton].
+// See [StaticAPIKeys] for a validator with a fixed set of keys.
+//
+// The 401 responses have the "WWW-Authenticate" header with the "APIKey" scheme.
+func APIKey[U any](header headers.Header, query string, v AuthValidator[U], h josh.Handler) josh.Handler {
+	return func(r josh.Req) josh.Resp {
+		key := r.Header.Get(string(header))
+		fromQuery := false
+		if key == "" && query != "" {
+			key = r.URL.Query().Get(query)
+			fromQuery = true
+		}
+		if key == "" {
+			authErr := &AuthError{Description: "API key not found"}
+			josh.SetHeader(r, headers.WWWAuthenticate, authErr.challenge(apiKeyScheme))
+			return authErr.resp("")
+		}
+		user, err := v(key)
+		if err != nil {
+			authErr := toAuthError(err, header)
+			if authErr.status() == statuses.Unauthorized {
+				josh.SetHeader(r, headers.WWWAuthenticate, authErr.challenge(apiKeyScheme))
+			}
+			resp := authErr.resp(header)
+			if fromQuery {
+				resp.Errors[0].Source = josh.SourceParameter(query)
+			}
+			return resp
+		}
+		r = setUser(r, user)
+		return h(r)
+	}
+}
+
+// Create an [AuthValidator] for [APIKey] from a fixed map of keys to users.
+//
+// The keys are compared in constant time to not leak them through timing.
+func StaticAPIKeys[U any](keys map[string]U) AuthValidator[U] {
+	type entry struct {
+		hash [sha256.Size]byte
+		user U
+	}
+	// Comparing hashes makes the comparison time independent of the key length.
+	entries := make([]entry, 0, len(keys))
+	for key, user := range keys {
+		entries = append(entries, entry{hash: sha256.Sum256([]byte(key)), user: user})
+	}
+	return func(key string) (U, error) {
+		hash := sha256.Sum256([]byte(key))
+		var user U
+		found := 0
+		// Check all keys, even after a match.
+		for _, e := range entries {
+			if subtle.ConstantTimeCompare(hash[:], e.hash[:]) == 1 {
+				user = e.user
+				found = 1
+			}
+		}
+		if found == 0 {
+			return user, errors.New("Invalid API key")
+		}
+		return user, nil
+	}
+}
@@ -0,0 +1,55 @@
+package middlewares_test
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/orsinium-labs/josh"
+	"github.com/orsinium-labs/josh/middlewares"
+)
+
+func TestAPIKey(t *testing.T) {
+	v := middlewares.StaticAPIKeys(map[string]string{"secret": "Aragorn"})
+	h := josh.Wrap(middlewares.APIKey("X-API-Key", "api_key", v, func(r josh.Req) josh.Resp {
+		return josh.Ok(josh.Must(josh.GetSingleton[string](r)))
+	}))
+	check := func(header, url string, code int, challenge, body string) {
+		t.Helper()
+		req := httptest.NewRequest("GET", "http://example.com"+url, nil)
+		if header != "" {
+			req.Header.Set("X-API-Key", header)
+		}
+		w := httptest.NewRecorder()
+		h(w, req)
+		eq(w.Code, code)
+		eq(w.Header().Get("WWW-Authenticate"), challenge)
+		eq(strings.Contains(w.Body.String(), body), true)
+	}
+	check("secret", "/", 200, "", `"Aragorn"`)
+	check("", "/?api_key=secret", 200, "", `"Aragorn"`)
+	check("", "/", 401, "APIKey", `"API key not found"`)
+	check("ohno", "/", 401, `APIKey error="invalid_token", error_description="Invalid API key"`, `"source":{"header":"X-API-Key"}`)
+	check("", "/?api_key=ohno", 401, `APIKey error="invalid_token", error_description="Invalid API key"`, `"source":{"parameter":"api_key"}`)
+}
+
+func TestAPIKey_Nested(t *testing.T) {
+	type Service struct{ Name string }
+	type User struct{ Name string }
+	services := middlewares.StaticAPIKeys(map[string]Service{"svc-key": {"gondor"}})
+	users := func(token string) (User, error) {
+		return User{"Aragorn"}, nil
+	}
+	h := josh.Wrap(middlewares.APIKey("X-API-Key", "", services, middlewares.Auth(users, func(r josh.Req) josh.Resp {
+		service := josh.Must(josh.GetSingleton[Service](r))
+		user := josh.Must(josh.GetSingleton[User](r))
+		return josh.Ok(service.Name + "/" + user.Name)
+	})))
+	req := httptest.NewRequest("GET", "http://example.com/", nil)
+	req.Header.Set("X-API-Key", "svc-key")
+	req.Header.Set("Authorization", "Bearer token")
+	w := httptest.NewRecorder()
+	h(w, req)
+	eq(w.Code, 200)
+	eq(strings.TrimSpace(w.Body.String()), `{"data":"gondor/Aragorn"}`)
+}
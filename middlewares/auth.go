@@ -5,10 +5,127 @@ import (
 	"strings"
 
 	"github.com/orsinium-labs/josh"
+	"github.com/orsinium-labs/josh/headers"
+	"github.com/orsinium-labs/josh/statuses"
 )
 
 type AuthValidator[U any] func(string) (U, error)
 
+// Error codes for [AuthError].
+//
+// https://datatracker.ietf.org/doc/html/rfc6750#section-3.1
+const (
+	// The request is malformed. Responded with 400.
+	CodeInvalidRequest = "invalid_request"
+
+	// The token is expired, revoked, malformed, or invalid for other reasons. Responded with 401.
+	CodeInvalidToken = "invalid_token"
+
+	// The token is valid but doesn't grant access to the resource. Responded with 403.
+	CodeInsufficientScope = "insufficient_scope"
+)
+
+// AuthError is an error that [AuthValidator] can return to control the response.
+//
+// Any other error returned by the validator is treated as [CodeInvalidToken].
+type AuthError struct {
+	// One of [CodeInvalidRequest], [CodeInvalidToken], or [CodeInsufficientScope].
+	//
+	// [Authorize] also uses [CodeUnauthenticated], [CodeInsufficientRole], and [CodeForbidden].
+	Code string
+
+	// Human-readable explanation of the error.
+	Description string
+
+	// Space-separated list of scopes required to access the resource.
+	//
+	// Used with [CodeInsufficientScope].
+	Scope string
+}
+
+func (err *AuthError) Error() string {
+	if err.Description == "" {
+		return err.Code
+	}
+	return err.Description
+}
+
+// The status code for the error.
+func (err *AuthError) status() statuses.Status {
+	switch err.Code {
+	case CodeInvalidRequest:
+		return statuses.BadRequest
+	case CodeInsufficientScope, CodeInsufficientRole, CodeForbidden:
+		return statuses.Forbidden
+	}
+	return statuses.Unauthorized
+}
+
+// The value of "WWW-Authenticate" header for the error.
+//
+// Error attributes are omitted if there is no error code or the code is
+// [CodeUnauthenticated], which is the case when the request has no credentials.
+func (err *AuthError) challenge(scheme string) string {
+	if err.Code == "" || err.Code == CodeUnauthenticated {
+		return scheme
+	}
+	params := []string{`error="` + quote(err.Code) + `"`}
+	if err.Description != "" {
+		params = append(params, `error_description="`+quote(err.Description)+`"`)
+	}
+	if err.Scope != "" {
+		params = append(params, `scope="`+quote(err.Scope)+`"`)
+	}
+	return scheme + " " + strings.Join(params, ", ")
+}
+
+// The JSON:API error response for the error.
+func (err *AuthError) resp(source headers.Header) josh.Resp {
+	jErr := josh.Error{
+		Detail: err.Error(),
+		Code:   err.Code,
+	}
+	if source != "" {
+		jErr.Source = josh.SourceHeader(string(source))
+	}
+	return josh.Resp{
+		Status: err.status(),
+		Errors: []josh.Error{jErr},
+	}
+}
+
+// Convert the validator error into [AuthError].
+//
+// Errors other than [AuthError] are treated as [CodeInvalidToken].
+// If the source is empty, no credentials were found in the request
+// and the error has no code.
+func toAuthError(err error, source headers.Header) *AuthError {
+	var authErr *AuthError
+	if errors.As(err, &authErr) {
+		return authErr
+	}
+	if source == "" {
+		return &AuthError{Description: err.Error()}
+	}
+	return &AuthError{Code: CodeInvalidToken, Description: err.Error()}
+}
+
+// Escape the string for an HTTP quoted-string, dropping control characters.
+func quote(s string) string {
+	var b strings.Builder
+	for _, c := range s {
+		switch {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(c)
+		case c < 0x20 || c == 0x7f:
+		default:
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
 // Authenticate the request using the provided validator before proceeding to the handler.
 //
 // The validators input is the "Bearer" token provided in the "Authorization" request header.
@@ -25,58 +142,85 @@ type AuthValidator[U any] func(string) (U, error)
 // If the validator returns an error, that error is immediately returned
 // as an "Unathorized" response. Otherwise, the returned value (typically, the user
 // or their ID) is added into the request context using [josh.WithSingleton].
+//
+// The error response has the "WWW-Authenticate" header as required by RFC 6750
+// and the error source points to the header the token was taken from.
+// Return [AuthError] from the validator to respond with 400 or 403 instead.
+//
+// https://datatracker.ietf.org/doc/html/rfc6750#section-3
 func Auth[U any](v AuthValidator[U], h josh.Handler) josh.Handler {
 	return func(r josh.Req) josh.Resp {
-		user, err := validateRequest(v, r)
+		user, source, err := validateRequest(v, r)
 		if err != nil {
-			return josh.Unauthorized(
-				josh.Error{Detail: err.Error()},
-			)
+			authErr := toAuthError(err, source)
+			josh.SetHeader(r, headers.WWWAuthenticate, authErr.challenge("Bearer"))
+			return authErr.resp(source)
 		}
-		r = josh.Must(josh.WithSingleton(r, user))
+		r = setUser(r, user)
 		return h(r)
 	}
 }
 
-func validateRequest[U any](validator AuthValidator[U], r josh.Req) (U, error) {
-	header := r.Header.Get("Authorization")
+// The user set by auth middlewares, for middlewares that don't know the user type.
+//
+// If auth middlewares are nested, the innermost user wins.
+type authUser struct {
+	user any
+}
+
+// Add the authenticated user into the request context.
+func setUser[U any](r josh.Req, user U) josh.Req {
+	r = josh.Must(josh.WithSingleton(r, user))
+	slot, err := josh.GetSingleton[*userSlot](r)
+	if err == nil {
+		slot.put(user)
+	}
+	return josh.ReplaceSingleton(r, authUser{user: user})
+}
+
+// Validate the token and return the header the token was taken from.
+//
+// The header is empty if no token is found.
+func validateRequest[U any](validator AuthValidator[U], r josh.Req) (U, headers.Header, error) {
+	header := r.Header.Get(string(headers.Authorization))
 	if header == "" {
 		// If Authorization header is not provided,
 		// try authenticating it as a WebSocket request.
 		return validateWSRequest(validator, r)
 	}
+	var def U
 	token, hasPrefix := strings.CutPrefix(header, "Bearer ")
 	if !hasPrefix {
-		var def U
-		return def, errors.New("Unsupported Authorization type")
+		return def, "", errors.New("Unsupported Authorization type")
 	}
 	token = strings.TrimSpace(token)
 	if token == "" {
-		var def U
-		return def, errors.New("Authorization token is empty")
+		return def, headers.Authorization, errors.New("Authorization token is empty")
 	}
-	return validator(token)
+	user, err := validator(token)
+	return user, headers.Authorization, err
 }
 
-func validateWSRequest[U any](validator AuthValidator[U], r josh.Req) (U, error) {
-	// https://developer.mozilla.org/en-US/docs/Web/HTTP/Headers/Sec-WebSocket-Protocol
-	headers := r.Header.Values("Sec-WebSocket-Protocol")
+func validateWSRequest[U any](validator AuthValidator[U], r josh.Req) (U, headers.Header, error) {
+	const source = headers.SecWebSocketProtocol
+	values := r.Header.Values(string(source))
 	var def U
-	if len(headers) == 0 {
-		return def, errors.New("Authorization header not found")
+	if len(values) == 0 {
+		return def, "", errors.New("Authorization header not found")
 	}
 
 	foundAuth := false
-	for _, tokens := range headers {
+	for _, tokens := range values {
 		for _, token := range strings.Split(tokens, ",") {
 			token = strings.TrimSpace(token)
 			if token == "Authorization" {
 				foundAuth = true
 			} else if foundAuth {
-				return validator(token)
+				user, err := validator(token)
+				return user, source, err
 			}
 		}
 	}
 
-	return def, errors.New("Authorization header not found")
+	return def, "", errors.New("Authorization header not found")
 }
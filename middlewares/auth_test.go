@@ -5,6 +5,7 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/orsinium-labs/josh"
@@ -97,3 +98,50 @@ func checkAuth(t *testing.T, req *http.Request, code int) {
 		t.Fatalf("got %d, expected %d", resp.StatusCode, code)
 	}
 }
+
+func TestAuth_Challenge(t *testing.T) {
+	v := func(token string) (string, error) {
+		switch token {
+		case "reader":
+			return "", &middlewares.AuthError{
+				Code:        middlewares.CodeInsufficientScope,
+				Description: "write access required",
+				Scope:       "write",
+			}
+		case "broken":
+			return "", &middlewares.AuthError{Code: middlewares.CodeInvalidRequest}
+		}
+		return "", fmt.Errorf(`bad "token"`)
+	}
+	h := josh.Wrap(middlewares.Auth(v, func(r josh.Req) josh.Resp {
+		return josh.Ok("all is good")
+	}))
+	check := func(header, value string, code int, challenge, body string) {
+		t.Helper()
+		req := httptest.NewRequest("GET", "http://example.com/foo", nil)
+		if header != "" {
+			req.Header.Add(header, value)
+		}
+		w := httptest.NewRecorder()
+		h(w, req)
+		eq(w.Code, code)
+		eq(w.Header().Get("WWW-Authenticate"), challenge)
+		eq(strings.Contains(w.Body.String(), body), true)
+	}
+	check("", "", 401, "Bearer", `"detail":"Authorization header not found"`)
+	check(
+		"Authorization", "Bearer ohno", 401,
+		`Bearer error="invalid_token", error_description="bad \"token\""`,
+		`"source":{"header":"Authorization"}`,
+	)
+	check(
+		"Authorization", "Bearer reader", 403,
+		`Bearer error="insufficient_scope", error_description="write access required", scope="write"`,
+		`"code":"insufficient_scope"`,
+	)
+	check(
+		"Sec-WebSocket-Protocol", "Authorization, broken", 400,
+		`Bearer error="invalid_request"`,
+		`"source":{"header":"Sec-WebSocket-Protocol"}`,
+	)
+}
@@ -0,0 +1,134 @@
+package middlewares
+
+import (
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/orsinium-labs/josh"
+	"github.com/orsinium-labs/josh/headers"
+)
+
+// Error codes for [Authorize], in addition to the ones for [AuthError].
+const (
+	// The request has no authenticated user. Responded with 401.
+	CodeUnauthenticated = "unauthenticated"
+
+	// The user doesn't have any of the required roles. Responded with 403.
+	CodeInsufficientRole = "insufficient_role"
+
+	// The policy denied access for any other reason. Responded with 403.
+	CodeForbidden = "forbidden"
+)
+
+// The error that a [Policy] can return to deny access without any details.
+var ErrForbidden = &AuthError{Code: CodeForbidden, Description: "Access denied"}
+
+// Policy decides if the user is allowed to make the request.
+//
+// Return nil to allow the request. Return [AuthError] (or [ErrForbidden])
+// to deny access. Any other error is treated as a failure to make the decision,
+// like a database error, and is logged and responded with 500.
+type Policy[U any] func(r josh.Req, user U) error
+
+// Check permissions of the user set by [Auth] before proceeding to the handler.
+//
+// If there is no user of type U in the request context, 401 is returned
+// with the "Bearer" challenge in the "WWW-Authenticate" header.
+// If the policy denies access, 403 is returned with the "WWW-Authenticate"
+// header describing the error, like the missing scopes.
+// The logger for policy failures is taken from the request context (see [WithLogger]).
+//
+//	h = middlewares.Authorize(middlewares.RequireScopes(User.Scopes, "posts:write"), h)
+//	h = middlewares.Auth(validator, h)
+func Authorize[U any](p Policy[U], h josh.Handler) josh.Handler {
+	return func(r josh.Req) josh.Resp {
+		user, err := josh.GetSingleton[U](r)
+		if err != nil {
+			authErr := &AuthError{Code: CodeUnauthenticated, Description: "Authentication required"}
+			josh.SetHeader(r, headers.WWWAuthenticate, authErr.challenge("Bearer"))
+			return authErr.resp("")
+		}
+		err = p(r, user)
+		if err != nil {
+			var authErr *AuthError
+			if !errors.As(err, &authErr) {
+				logger, _ := josh.GetSingleton[*slog.Logger](r)
+				if logger != nil {
+					logger.ErrorContext(r.Context(), "authorization policy failed", "error", err)
+				}
+				return josh.InternalServerError(josh.Error{
+					Title:  "Authorization failed",
+					Detail: "Cannot check the permissions",
+				})
+			}
+			josh.SetHeader(r, headers.WWWAuthenticate, authErr.challenge("Bearer"))
+			return authErr.resp("")
+		}
+		return h(r)
+	}
+}
+
+// Convert the policy into a [josh.Middleware] that can be used with [josh.Endpoint.With].
+func (p Policy[U]) Middleware() josh.Middleware {
+	return josh.Lift(func(h josh.Handler) josh.Handler {
+		return Authorize(p, h)
+	})
+}
+
+// Require the user to have all the given scopes.
+//
+// The scopes function extracts the scopes from the user.
+func RequireScopes[U any](scopes func(U) []string, required ...string) Policy[U] {
+	return func(r josh.Req, user U) error {
+		actual := scopes(user)
+		var missing []string
+		for _, scope := range required {
+			if !slices.Contains(actual, scope) {
+				missing = append(missing, scope)
+			}
+		}
+		if len(missing) == 0 {
+			return nil
+		}
+		return &AuthError{
+			Code:        CodeInsufficientScope,
+			Description: "Missing required scopes: " + strings.Join(missing, ", "),
+			Scope:       strings.Join(required, " "),
+		}
+	}
+}
+
+// Require the user to have at least one of the given roles.
+//
+// The roles function extracts the roles from the user.
+func RequireRoles[U any](roles func(U) []string, anyOf ...string) Policy[U] {
+	return func(r josh.Req, user U) error {
+		actual := roles(user)
+		for _, role := range anyOf {
+			if slices.Contains(actual, role) {
+				return nil
+			}
+		}
+		return &AuthError{
+			Code:        CodeInsufficientRole,
+			Description: "One of the following roles is required: " + strings.Join(anyOf, ", "),
+		}
+	}
+}
+
+// Combine policies so that all of them must allow the request.
+//
+// The policies are checked in order and the first error is returned.
+func AllOf[U any](policies ...Policy[U]) Policy[U] {
+	return func(r josh.Req, user U) error {
+		for _, p := range policies {
+			err := p(r, user)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,63 @@
+package middlewares_test
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/orsinium-labs/josh"
+	"github.com/orsinium-labs/josh/middlewares"
+)
+
+type Member struct {
+	Name   string
+	Roles  []string
+	Scopes []string
+}
+
+func TestAuthorize(t *testing.T) {
+	roles := func(m Member) []string { return m.Roles }
+	scopes := func(m Member) []string { return m.Scopes }
+	policy := middlewares.AllOf(
+		middlewares.RequireRoles(roles, "king", "steward"),
+		middlewares.RequireScopes(scopes, "read", "write"),
+		func(r josh.Req, m Member) error {
+			if m.Name == "Denethor" {
+				return middlewares.ErrForbidden
+			}
+			if m.Name == "Saruman" {
+				return errors.New("connection refused to 10.0.0.1")
+			}
+			return nil
+		},
+	)
+	endpoint := josh.Endpoint{
+		GET: josh.Wrap(func(r josh.Req) josh.Resp { return josh.Ok("hi") }),
+	}.With(policy.Middleware())
+
+	check := func(m *Member, code int, challenge, body string) {
+		t.Helper()
+		req := httptest.NewRequest("GET", "http://example.com/", nil)
+		if m != nil {
+			req = josh.Must(josh.WithSingleton(req, *m))
+		}
+		w := httptest.NewRecorder()
+		endpoint.GET(w, req)
+		eq(w.Code, code)
+		eq(w.Header().Get("WWW-Authenticate"), challenge)
+		eq(strings.Contains(w.Body.String(), body), true)
+	}
+	check(&Member{"Aragorn", []string{"king"}, []string{"read", "write"}}, 200, "", `"hi"`)
+	check(nil, 401, "Bearer", `"code":"unauthenticated"`)
+	check(&Member{"Boromir", []string{"captain"}, []string{"read", "write"}}, 403,
+		`Bearer error="insufficient_role", error_description="One of the following roles is required: king, steward"`,
+		`"code":"insufficient_role"`)
+	check(&Member{"Faramir", []string{"steward"}, []string{"read"}}, 403,
+		`Bearer error="insufficient_scope", error_description="Missing required scopes: write", scope="read write"`,
+		`"Missing required scopes: write"`)
+	check(&Member{"Denethor", []string{"steward"}, []string{"read", "write"}}, 403,
+		`Bearer error="forbidden", error_description="Access denied"`, `"code":"forbidden"`)
+	// Policy failures are not leaked to the client.
+	check(&Member{"Saruman", []string{"steward"}, []string{"read", "write"}}, 500, "", `"Cannot check the permissions"`)
+}
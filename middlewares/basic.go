@@ -0,0 +1,74 @@
+package middlewares
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+
+	"github.com/orsinium-labs/josh"
+	"github.com/orsinium-labs/josh/headers"
+	"github.com/orsinium-labs/josh/statuses"
+)
+
+// BasicValidator checks the username and password and returns the user.
+//
+// Like [AuthValidator], it can return [AuthError] to control the response.
+type BasicValidator[U any] func(username, password string) (U, error)
+
+// Authenticate the request using HTTP Basic authentication before proceeding to the handler.
+//
+// The realm is included into the "WWW-Authenticate" header of the error response.
+// The returned user is added into the request context using [josh.WithSingleton].
+// See [BasicCredentials] for a validator with a fixed set of credentials.
+//
+// Basic authentication sends the password in plain text, so use it only over HTTPS.
+//
+// https://datatracker.ietf.org/doc/html/rfc7617
+func BasicAuth[U any](realm string, v BasicValidator[U], h josh.Handler) josh.Handler {
+	challenge := `Basic realm="` + quote(realm) + `", charset="UTF-8"`
+	return func(r josh.Req) josh.Resp {
+		username, password, ok := r.BasicAuth()
+		var user U
+		var err error
+		var source headers.Header
+		if ok {
+			source = headers.Authorization
+			user, err = v(username, password)
+		} else {
+			err = errors.New("Basic credentials not found")
+		}
+		if err != nil {
+			authErr := toAuthError(err, source)
+			if authErr.status() == statuses.Unauthorized {
+				josh.SetHeader(r, headers.WWWAuthenticate, challenge)
+			}
+			return authErr.resp(source)
+		}
+		r = setUser(r, user)
+		return h(r)
+	}
+}
+
+// Create a [BasicValidator] for [BasicAuth] from a fixed map of usernames to passwords.
+//
+// The returned user is the username. The credentials are compared in constant time.
+func BasicCredentials(passwords map[string]string) BasicValidator[string] {
+	hashes := make(map[string][sha256.Size]byte, len(passwords))
+	for username, password := range passwords {
+		hashes[username] = sha256.Sum256([]byte(password))
+	}
+	// Compare against a dummy hash for unknown users
+	// to not reveal which usernames exist.
+	var dummy [sha256.Size]byte
+	return func(username, password string) (string, error) {
+		expected, found := hashes[username]
+		if !found {
+			expected = dummy
+		}
+		actual := sha256.Sum256([]byte(password))
+		if subtle.ConstantTimeCompare(actual[:], expected[:]) != 1 || !found {
+			return "", errors.New("Invalid username or password")
+		}
+		return username, nil
+	}
+}
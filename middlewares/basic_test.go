@@ -0,0 +1,33 @@
+package middlewares_test
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/orsinium-labs/josh"
+	"github.com/orsinium-labs/josh/middlewares"
+)
+
+func TestBasicAuth(t *testing.T) {
+	v := middlewares.BasicCredentials(map[string]string{"aragorn": "anduril"})
+	h := josh.Wrap(middlewares.BasicAuth("gondor", v, func(r josh.Req) josh.Resp {
+		return josh.Ok(josh.Must(josh.GetSingleton[string](r)))
+	}))
+	check := func(user, pass string, code int) {
+		t.Helper()
+		req := httptest.NewRequest("GET", "http://example.com/", nil)
+		if user != "" {
+			req.SetBasicAuth(user, pass)
+		}
+		w := httptest.NewRecorder()
+		h(w, req)
+		eq(w.Code, code)
+		if code == 401 {
+			eq(w.Header().Get("WWW-Authenticate"), `Basic realm="gondor", charset="UTF-8"`)
+		}
+	}
+	check("aragorn", "anduril", 200)
+	check("", "", 401)
+	check("aragorn", "narsil", 401)
+	check("boromir", "anduril", 401)
+}
@@ -0,0 +1,126 @@
+package middlewares
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"slices"
+
+	"github.com/orsinium-labs/josh"
+	"github.com/orsinium-labs/josh/headers"
+	"github.com/orsinium-labs/josh/statuses"
+)
+
+// The name of the cookie with the CSRF token set by [CSRF].
+const CSRFCookie = "csrf_token"
+
+// CSRFConfig is the configuration for [CSRF].
+type CSRFConfig struct {
+	// Origins allowed to make unsafe cross-origin requests.
+	//
+	// Must be in the "scheme://host[:port]" format, like "https://example.com".
+	TrustedOrigins []string
+
+	// Allow sending the CSRF cookie over plain HTTP. Use it only for local development.
+	Insecure bool
+}
+
+// Protect from cross-site request forgery (CSRF) attacks.
+//
+// Required for services that authenticate browser clients using cookies.
+// Unsafe requests (everything except GET, HEAD, OPTIONS, and TRACE) are checked
+// in the following order:
+//
+//  1. If the "Sec-Fetch-Site" header is present (sent by all modern browsers),
+//     the request must be "same-origin", direct ("none"), or have a trusted "Origin".
+//  2. Otherwise, if the "Origin" header is present, it must match the request host
+//     or be trusted.
+//  3. Otherwise, the "X-CSRF-Token" header must match the "csrf_token" cookie
+//     (the double-submit cookie pattern). The cookie is issued on safe requests
+//     and is readable by JavaScript.
+//
+// Failed checks are responded with 403.
+//
+//	h = middlewares.CSRF(middlewares.CSRFConfig{
+//		TrustedOrigins: []string{"https://app.example.com"},
+//	}, h)
+//
+// https://cheatsheetseries.owasp.org/cheatsheets/Cross-Site_Request_Forgery_Prevention_Cheat_Sheet.html
+func CSRF(cfg CSRFConfig, h josh.Handler) josh.Handler {
+	return func(r josh.Req) josh.Resp {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+			_, err := r.Cookie(CSRFCookie)
+			if err != nil {
+				issueCSRFToken(r, !cfg.Insecure)
+			}
+			return h(r)
+		}
+		detail := checkCSRF(r, cfg.TrustedOrigins)
+		if detail != "" {
+			return josh.Resp{
+				Status: statuses.Forbidden,
+				Errors: []josh.Error{{
+					Title:  "Cross-site request forgery check failed",
+					Detail: detail,
+					Code:   "csrf_failed",
+				}},
+			}
+		}
+		return h(r)
+	}
+}
+
+// Check if the unsafe request is safe, actually.
+//
+// Returns the reason why the request is rejected or an empty string if it's allowed.
+func checkCSRF(r josh.Req, trustedOrigins []string) string {
+	origin := r.Header.Get(string(headers.Origin))
+	switch r.Header.Get(string(headers.SecFetchSite)) {
+	case "same-origin", "none":
+		return ""
+	case "":
+	default:
+		if slices.Contains(trustedOrigins, origin) {
+			return ""
+		}
+		return "Cross-origin requests are not allowed"
+	}
+
+	if origin != "" {
+		u, err := url.Parse(origin)
+		if err == nil && u.Host == r.Host {
+			return ""
+		}
+		if slices.Contains(trustedOrigins, origin) {
+			return ""
+		}
+		return "Cross-origin requests are not allowed"
+	}
+
+	cookie, err := r.Cookie(CSRFCookie)
+	if err != nil || cookie.Value == "" {
+		return "CSRF cookie not found"
+	}
+	token := r.Header.Get(string(headers.XCSRFToken))
+	if subtle.ConstantTimeCompare([]byte(token), []byte(cookie.Value)) != 1 {
+		return "CSRF token in the X-CSRF-Token header doesn't match the cookie"
+	}
+	return ""
+}
+
+// Set a new random CSRF token cookie.
+func issueCSRFToken(r josh.Req, secure bool) {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	w := josh.Must(josh.GetSingleton[http.ResponseWriter](r))
+	http.SetCookie(w, &http.Cookie{
+		Name:     CSRFCookie,
+		Value:    base64.RawURLEncoding.EncodeToString(b),
+		Path:     "/",
+		Secure:   secure,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
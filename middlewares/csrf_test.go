@@ -0,0 +1,63 @@
+package middlewares_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/orsinium-labs/josh"
+	"github.com/orsinium-labs/josh/middlewares"
+)
+
+func TestCSRF(t *testing.T) {
+	h := josh.Wrap(middlewares.CSRF(middlewares.CSRFConfig{
+		TrustedOrigins: []string{"https://app.example.com"},
+	}, func(r josh.Req) josh.Resp {
+		return josh.Ok("hi")
+	}))
+	send := func(method string, hdrs map[string]string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, "http://example.com/", nil)
+		for k, v := range hdrs {
+			req.Header.Set(k, v)
+		}
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		h(w, req)
+		return w
+	}
+
+	w := send("GET", nil, nil)
+	eq(w.Code, 200)
+	cookies := w.Result().Cookies()
+	eq(len(cookies), 1)
+	cookie := cookies[0]
+	eq(cookie.Name, "csrf_token")
+	eq(cookie.Secure, true)
+
+	eq(send("POST", map[string]string{"Sec-Fetch-Site": "same-origin"}, nil).Code, 200)
+	eq(send("POST", map[string]string{"Sec-Fetch-Site": "cross-site"}, nil).Code, 403)
+	eq(send("POST", map[string]string{
+		"Sec-Fetch-Site": "same-site",
+		"Origin":         "https://app.example.com",
+	}, nil).Code, 200)
+	eq(send("POST", map[string]string{"Origin": "http://example.com"}, nil).Code, 200)
+	eq(send("POST", map[string]string{"Origin": "https://evil.com"}, nil).Code, 403)
+	eq(send("POST", nil, nil).Code, 403)
+	eq(send("POST", map[string]string{"X-CSRF-Token": "ohno"}, cookie).Code, 403)
+	eq(send("POST", map[string]string{"X-CSRF-Token": cookie.Value}, cookie).Code, 200)
+}
+
+func TestCSRF_Insecure(t *testing.T) {
+	h := josh.Wrap(middlewares.CSRF(middlewares.CSRFConfig{Insecure: true}, func(r josh.Req) josh.Resp {
+		return josh.Ok("hi")
+	}))
+	req := httptest.NewRequest("GET", "http://localhost/", nil)
+	w := httptest.NewRecorder()
+	h(w, req)
+	cookies := w.Result().Cookies()
+	eq(len(cookies), 1)
+	eq(cookies[0].Secure, false)
+}
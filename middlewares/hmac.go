@@ -0,0 +1,213 @@
+package middlewares
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/orsinium-labs/josh"
+	"github.com/orsinium-labs/josh/headers"
+	"github.com/orsinium-labs/josh/statuses"
+)
+
+// The maximum size of the request body that [HMAC] reads to verify the signature.
+const maxSignedBody = 1024 * 1024
+
+// The authentication scheme used by [HMAC] and [SignRequest].
+const hmacScheme = "HMAC-SHA256"
+
+// HMACKeys returns the secret and the user for the given key ID.
+type HMACKeys[U any] func(keyID string) (secret []byte, user U, err error)
+
+// Authenticate HMAC-signed requests before proceeding to the handler.
+//
+// The request must have the "Date" header, the "X-Nonce" header with a unique
+// random value, and the "Authorization" header in the following format:
+//
+//	Authorization: HMAC-SHA256 <key ID>:<base64 signature>
+//
+// The signature is HMAC-SHA256 of the method, the request URI (path and query),
+// the "Date" header value, the "X-Nonce" header value, and the hex-encoded
+// SHA-256 digest of the body, separated by newlines.
+// Use [SignRequest] to sign requests on the client side.
+//
+// To protect against replay attacks, the date must be within the window
+// from the current time and each nonce is accepted only once for the key.
+// So identical requests sent within the same second are accepted
+// as long as they have different nonces.
+// The seen nonces are kept in memory, so if you run multiple instances
+// of the service, a replayed request can still be accepted by another instance
+// within the window.
+//
+// The returned user is added into the request context using [josh.WithSingleton].
+func HMAC[U any](keys HMACKeys[U], window time.Duration, h josh.Handler) josh.Handler {
+	seen := &seenNonces{seen: make(map[string]time.Time)}
+	return func(r josh.Req) josh.Resp {
+		keyID, sig, err := parseHMACHeader(r.Header.Get(string(headers.Authorization)))
+		if err != nil {
+			authErr := toAuthError(err, "")
+			josh.SetHeader(r, headers.WWWAuthenticate, hmacScheme)
+			return authErr.resp("")
+		}
+		fail := func(err error) josh.Resp {
+			authErr := toAuthError(err, headers.Authorization)
+			if authErr.status() == statuses.Unauthorized {
+				josh.SetHeader(r, headers.WWWAuthenticate, hmacScheme)
+			}
+			return authErr.resp(headers.Authorization)
+		}
+
+		date, err := http.ParseTime(r.Header.Get(string(headers.Date)))
+		if err != nil {
+			authErr := &AuthError{Code: CodeInvalidRequest, Description: "Invalid or missing Date header"}
+			return authErr.resp(headers.Date)
+		}
+		now := time.Now()
+		if date.Before(now.Add(-window)) || date.After(now.Add(window)) {
+			return fail(errors.New("Request date is outside of the allowed window"))
+		}
+		nonce := r.Header.Get(string(headers.XNonce))
+		if nonce == "" {
+			authErr := &AuthError{Code: CodeInvalidRequest, Description: "Missing X-Nonce header"}
+			return authErr.resp(headers.XNonce)
+		}
+
+		var body []byte
+		if r.Body != nil {
+			body, err = io.ReadAll(io.LimitReader(r.Body, maxSignedBody+1))
+			if err != nil {
+				return josh.BadRequest(josh.Error{
+					Title:  "Cannot read request body",
+					Detail: err.Error(),
+				})
+			}
+			if len(body) > maxSignedBody {
+				return josh.Resp{
+					Status: statuses.RequestEntityTooLarge,
+					Errors: []josh.Error{{
+						Title:  "Request body is too large",
+						Detail: fmt.Sprintf("Signed request body must not exceed %d bytes", maxSignedBody),
+					}},
+				}
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		secret, user, err := keys(keyID)
+		if err != nil {
+			return fail(err)
+		}
+		expected := hmacSign(secret, r.Method, r.URL.RequestURI(), r.Header.Get(string(headers.Date)), nonce, body)
+		if !hmac.Equal(sig, expected) {
+			return fail(errors.New("Invalid request signature"))
+		}
+		if !seen.add(keyID+":"+nonce, date.Add(window), now) {
+			return fail(errors.New("Request nonce was already used"))
+		}
+		r = setUser(r, user)
+		return h(r)
+	}
+}
+
+// Sign the request for [HMAC].
+//
+// Sets the "Date" and "X-Nonce" headers (if not set yet) and the "Authorization" header.
+// The request body is read and replaced with an in-memory copy.
+func SignRequest(req *http.Request, keyID string, secret []byte) error {
+	date := req.Header.Get(string(headers.Date))
+	if date == "" {
+		date = time.Now().UTC().Format(http.TimeFormat)
+		req.Header.Set(string(headers.Date), date)
+	}
+	nonce := req.Header.Get(string(headers.XNonce))
+	if nonce == "" {
+		b := make([]byte, 16)
+		_, _ = rand.Read(b)
+		nonce = base64.RawURLEncoding.EncodeToString(b)
+		req.Header.Set(string(headers.XNonce), nonce)
+	}
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		if err != nil {
+			return fmt.Errorf("read request body: %w", err)
+		}
+		_ = req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+	}
+	sig := hmacSign(secret, req.Method, req.URL.RequestURI(), date, nonce, body)
+	value := hmacScheme + " " + keyID + ":" + base64.StdEncoding.EncodeToString(sig)
+	req.Header.Set(string(headers.Authorization), value)
+	return nil
+}
+
+func hmacSign(secret []byte, method, uri, date, nonce string, body []byte) []byte {
+	digest := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(method + "\n" + uri + "\n" + date + "\n" + nonce + "\n"))
+	mac.Write([]byte(hex.EncodeToString(digest[:])))
+	return mac.Sum(nil)
+}
+
+// Extract the key ID and the signature from the "Authorization" header.
+func parseHMACHeader(header string) (string, []byte, error) {
+	if header == "" {
+		return "", nil, errors.New("Authorization header not found")
+	}
+	creds, hasPrefix := strings.CutPrefix(header, hmacScheme+" ")
+	if !hasPrefix {
+		return "", nil, errors.New("Unsupported Authorization type")
+	}
+	keyID, rawSig, found := strings.Cut(strings.TrimSpace(creds), ":")
+	if !found || keyID == "" {
+		return "", nil, errors.New("Authorization header must be in <key ID>:<signature> format")
+	}
+	sig, err := base64.StdEncoding.DecodeString(rawSig)
+	if err != nil {
+		return "", nil, errors.New("Signature must be base64-encoded")
+	}
+	return keyID, sig, nil
+}
+
+// The nonces accepted by [HMAC] that haven't expired yet.
+type seenNonces struct {
+	mu        sync.Mutex
+	seen      map[string]time.Time
+	lastSweep time.Time
+}
+
+// Remember the nonce until the expiration time.
+//
+// Returns false if the nonce was already seen.
+func (s *seenNonces) add(nonce string, expires, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now.Sub(s.lastSweep) >= time.Minute {
+		for key, exp := range s.seen {
+			if now.After(exp) {
+				delete(s.seen, key)
+			}
+		}
+		s.lastSweep = now
+	}
+	exp, found := s.seen[nonce]
+	if found && !now.After(exp) {
+		return false
+	}
+	s.seen[nonce] = expires
+	return true
+}
@@ -0,0 +1,93 @@
+package middlewares_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/orsinium-labs/josh"
+	"github.com/orsinium-labs/josh/middlewares"
+)
+
+func TestHMAC(t *testing.T) {
+	keys := func(keyID string) ([]byte, string, error) {
+		if keyID == "gondor" {
+			return []byte("secret"), "Aragorn", nil
+		}
+		return nil, "", errors.New("Unknown key")
+	}
+	h := josh.Wrap(middlewares.HMAC(keys, time.Minute, func(r josh.Req) josh.Resp {
+		return josh.Ok(josh.Must(josh.GetSingleton[string](r)))
+	}))
+	send := func(req *http.Request) int {
+		w := httptest.NewRecorder()
+		h(w, req)
+		return w.Code
+	}
+	newReq := func() *http.Request {
+		return httptest.NewRequest("POST", "http://example.com/foo?bar=1", strings.NewReader(`{"data":1}`))
+	}
+
+	req := newReq()
+	eq(middlewares.SignRequest(req, "gondor", []byte("secret")), nil)
+	auth := req.Header.Get("Authorization")
+	date := req.Header.Get("Date")
+	nonce := req.Header.Get("X-Nonce")
+	eq(send(req), 200)
+
+	// Replay of the same request.
+	req = newReq()
+	req.Header.Set("Authorization", auth)
+	req.Header.Set("Date", date)
+	req.Header.Set("X-Nonce", nonce)
+	eq(send(req), 401)
+
+	// An identical request with a new nonce within the same second.
+	req = newReq()
+	req.Header.Set("Date", date)
+	eq(middlewares.SignRequest(req, "gondor", []byte("secret")), nil)
+	eq(send(req), 200)
+
+	// Tampered nonce.
+	req = newReq()
+	eq(middlewares.SignRequest(req, "gondor", []byte("secret")), nil)
+	req.Header.Set("X-Nonce", "ohno")
+	eq(send(req), 401)
+
+	// No nonce.
+	req = newReq()
+	eq(middlewares.SignRequest(req, "gondor", []byte("secret")), nil)
+	req.Header.Del("X-Nonce")
+	eq(send(req), 400)
+
+	// Tampered body.
+	req = newReq()
+	eq(middlewares.SignRequest(req, "gondor", []byte("secret")), nil)
+	req.Body = http.NoBody
+	eq(send(req), 401)
+
+	// Wrong secret and unknown key.
+	req = newReq()
+	eq(middlewares.SignRequest(req, "gondor", []byte("ohno")), nil)
+	eq(send(req), 401)
+	req = newReq()
+	eq(middlewares.SignRequest(req, "mordor", []byte("secret")), nil)
+	eq(send(req), 401)
+
+	// Outside of the window.
+	req = newReq()
+	req.Header.Set("Date", time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat))
+	eq(middlewares.SignRequest(req, "gondor", []byte("secret")), nil)
+	eq(send(req), 401)
+
+	// No date.
+	req = newReq()
+	eq(middlewares.SignRequest(req, "gondor", []byte("secret")), nil)
+	req.Header.Del("Date")
+	eq(send(req), 400)
+
+	eq(send(newReq()), 401)
+}
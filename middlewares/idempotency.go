@@ -0,0 +1,299 @@
+package middlewares
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/orsinium-labs/josh"
+	"github.com/orsinium-labs/josh/headers"
+	"github.com/orsinium-labs/josh/statuses"
+)
+
+// The maximum size of the request body that [Idempotency] reads to fingerprint the request.
+const maxIdempotentBody = 1024 * 1024
+
+// IdempotencyRecord is what [IdempotencyStore] keeps for each Idempotency-Key.
+type IdempotencyRecord struct {
+	// Hash of the request method, path, and body.
+	//
+	// Used to detect that the same key is reused for a different request.
+	Fingerprint string
+
+	// False if the first request with the key is still being handled.
+	Done bool
+
+	// The response status code.
+	Status statuses.Status
+
+	// The response headers set by the handler.
+	Header http.Header
+
+	// The JSON-encoded response body.
+	Body []byte
+}
+
+// IdempotencyStore keeps responses for [Idempotency] middleware.
+//
+// The implementation must be safe for concurrent use.
+// See [NewMemoryIdempotencyStore] for an in-memory implementation.
+type IdempotencyStore interface {
+	// Reserve the key for a new request with the given fingerprint.
+	//
+	// If the key is already known, the existing record is returned
+	// and the second return value is true. Otherwise, the store must
+	// atomically save a record with Done=false for the key.
+	Reserve(key, fingerprint string) (IdempotencyRecord, bool)
+
+	// Save the response for the previously reserved key.
+	Save(key string, rec IdempotencyRecord)
+
+	// Forget the key, so that the request can be retried.
+	Delete(key string)
+}
+
+// Make POST and PATCH requests with the "Idempotency-Key" header safe to retry.
+//
+// The first response for each key is saved in the store and replayed for all retries.
+// If the first request is still in flight, 409 Conflict is returned.
+// If the key is reused for a request with a different method, path, or body,
+// 422 Unprocessable Entity is returned. Requests with a body larger than 1 MB
+// get 413 Content Too Large.
+//
+// Server errors and responses written directly into [http.ResponseWriter]
+// are not saved, so the client can retry the request.
+//
+// The key is not scoped by user. If keys can be guessed, wrap this middleware
+// into an authentication middleware and include the user into the store key.
+//
+// https://datatracker.ietf.org/doc/draft-ietf-httpapi-idempotency-key-header/
+func Idempotency(store IdempotencyStore, h josh.Handler) josh.Handler {
+	return func(r josh.Req) josh.Resp {
+		if r.Method != http.MethodPost && r.Method != http.MethodPatch {
+			return h(r)
+		}
+		key := r.Header.Get(string(headers.IdempotencyKey))
+		if key == "" {
+			return h(r)
+		}
+		var body []byte
+		if r.Body != nil {
+			var err error
+			body, err = io.ReadAll(io.LimitReader(r.Body, maxIdempotentBody+1))
+			if err != nil {
+				return josh.BadRequest(josh.Error{
+					Title:  "Cannot read request body",
+					Detail: err.Error(),
+				})
+			}
+			if len(body) > maxIdempotentBody {
+				return josh.Resp{
+					Status: statuses.RequestEntityTooLarge,
+					Errors: []josh.Error{{
+						Title:  "Request body is too large",
+						Detail: fmt.Sprintf("Request body with Idempotency-Key must not exceed %d bytes", maxIdempotentBody),
+					}},
+				}
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		fp := fingerprint(r, body)
+
+		rec, found := store.Reserve(key, fp)
+		if found {
+			return replay(r, rec, fp)
+		}
+
+		saved := false
+		defer func() {
+			// The handler failed or panicked, let the client retry.
+			if !saved {
+				store.Delete(key)
+			}
+		}()
+		// Remember the headers set by outer middlewares,
+		// so that only the headers set by the handler are saved.
+		var before http.Header
+		w, err := josh.GetSingleton[http.ResponseWriter](r)
+		if err == nil {
+			before = w.Header().Clone()
+		}
+		resp := h(r)
+		if resp.Status == 0 || resp.Status.IsServerError() || josh.Canceled(r) {
+			return resp
+		}
+		raw, err := json.Marshal(resp)
+		if err != nil {
+			return resp
+		}
+		rec = IdempotencyRecord{
+			Fingerprint: fp,
+			Done:        true,
+			Status:      resp.Status,
+			Body:        raw,
+		}
+		if w != nil {
+			rec.Header = addedHeaders(before, w.Header())
+		}
+		store.Save(key, rec)
+		saved = true
+		return resp
+	}
+}
+
+// Produce the response for a request with an already known Idempotency-Key.
+func replay(r josh.Req, rec IdempotencyRecord, fp string) josh.Resp {
+	if rec.Fingerprint != fp {
+		return josh.Resp{
+			Status: statuses.UnprocessableEntity,
+			Errors: []josh.Error{{
+				Title:  "Idempotency key reused",
+				Detail: "The Idempotency-Key was already used for a different request",
+				Source: josh.SourceHeader(string(headers.IdempotencyKey)),
+			}},
+		}
+	}
+	if !rec.Done {
+		return josh.Resp{
+			Status: statuses.Conflict,
+			Errors: []josh.Error{{
+				Title:  "Request in progress",
+				Detail: "A request with the same Idempotency-Key is still being processed",
+				Source: josh.SourceHeader(string(headers.IdempotencyKey)),
+			}},
+		}
+	}
+	w, err := josh.GetSingleton[http.ResponseWriter](r)
+	if err == nil {
+		for name, values := range rec.Header {
+			w.Header()[name] = slices.Clone(values)
+		}
+	}
+	var doc struct {
+		Data     json.RawMessage `json:"data"`
+		Errors   []josh.Error    `json:"errors"`
+		Included json.RawMessage `json:"included"`
+		JSONAPI  json.RawMessage `json:"jsonapi"`
+		Links    json.RawMessage `json:"links"`
+		Meta     json.RawMessage `json:"meta"`
+	}
+	_ = json.Unmarshal(rec.Body, &doc)
+	resp := josh.Resp{Status: rec.Status, Errors: doc.Errors}
+	if doc.Data != nil {
+		resp.Data = doc.Data
+	}
+	if doc.Included != nil {
+		resp.Included = doc.Included
+	}
+	if doc.JSONAPI != nil {
+		resp.JSONAPI = doc.JSONAPI
+	}
+	if doc.Links != nil {
+		resp.Links = doc.Links
+	}
+	if doc.Meta != nil {
+		resp.Meta = doc.Meta
+	}
+	return resp
+}
+
+// Get the headers that were added or changed since the snapshot.
+func addedHeaders(before, after http.Header) http.Header {
+	added := make(http.Header)
+	for name, values := range after {
+		if !slices.Equal(before[name], values) {
+			added[name] = slices.Clone(values)
+		}
+	}
+	return added
+}
+
+// Hash the request method, path, and body.
+func fingerprint(r josh.Req, body []byte) string {
+	hasher := sha256.New()
+	hasher.Write([]byte(r.Method))
+	hasher.Write([]byte{0})
+	hasher.Write([]byte(r.URL.RequestURI()))
+	hasher.Write([]byte{0})
+	hasher.Write(body)
+	return hex.EncodeToString(hasher.Sum(nil))
+}
+
+// MemoryIdempotencyStore is an in-memory [IdempotencyStore].
+//
+// Records are forgotten after the TTL passes.
+// Must be constructed using [NewMemoryIdempotencyStore].
+type MemoryIdempotencyStore struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	records   map[string]memoryRecord
+	lastSweep time.Time
+}
+
+type memoryRecord struct {
+	IdempotencyRecord
+	expires time.Time
+}
+
+// Create a new in-memory [IdempotencyStore] with the given TTL for records.
+func NewMemoryIdempotencyStore(ttl time.Duration) *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{
+		ttl:       ttl,
+		records:   make(map[string]memoryRecord),
+		lastSweep: time.Now(),
+	}
+}
+
+// Reserve implements [IdempotencyStore].
+func (s *MemoryIdempotencyStore) Reserve(key, fingerprint string) (IdempotencyRecord, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.sweep(now)
+	rec, found := s.records[key]
+	if found && now.Before(rec.expires) {
+		return rec.IdempotencyRecord, true
+	}
+	s.records[key] = memoryRecord{
+		IdempotencyRecord: IdempotencyRecord{Fingerprint: fingerprint},
+		expires:           now.Add(s.ttl),
+	}
+	return IdempotencyRecord{}, false
+}
+
+// Save implements [IdempotencyStore].
+func (s *MemoryIdempotencyStore) Save(key string, rec IdempotencyRecord) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[key] = memoryRecord{
+		IdempotencyRecord: rec,
+		expires:           time.Now().Add(s.ttl),
+	}
+}
+
+// Delete implements [IdempotencyStore].
+func (s *MemoryIdempotencyStore) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, key)
+}
+
+// Remove all expired records, at most once per TTL.
+func (s *MemoryIdempotencyStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < s.ttl {
+		return
+	}
+	s.lastSweep = now
+	for key, rec := range s.records {
+		if !now.Before(rec.expires) {
+			delete(s.records, key)
+		}
+	}
+}
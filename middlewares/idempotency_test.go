@@ -0,0 +1,130 @@
+package middlewares_test
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/orsinium-labs/josh"
+	"github.com/orsinium-labs/josh/middlewares"
+)
+
+func TestIdempotency(t *testing.T) {
+	calls := 0
+	hf := func(r josh.Req) josh.Resp {
+		calls += 1
+		josh.SetHeader(r, "X-Call", "first")
+		return josh.Created("hi")
+	}
+	store := middlewares.NewMemoryIdempotencyStore(time.Minute)
+	h := josh.Wrap(middlewares.Idempotency(store, hf))
+	send := func(key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "http://example.com/foo", strings.NewReader(body))
+		req.Header.Set("Idempotency-Key", key)
+		w := httptest.NewRecorder()
+		h(w, req)
+		return w
+	}
+
+	w := send("k1", "hello")
+	eq(w.Code, 201)
+	eq(calls, 1)
+
+	// Retry is replayed without calling the handler.
+	w = send("k1", "hello")
+	eq(w.Code, 201)
+	eq(calls, 1)
+	eq(w.Header().Get("X-Call"), "first")
+	body := josh.Must(io.ReadAll(w.Result().Body))
+	eq(string(body), `{"data":"hi"}`+"\n")
+
+	// The same key with a different body.
+	w = send("k1", "world")
+	eq(w.Code, 422)
+	eq(calls, 1)
+
+	// A new key.
+	w = send("k2", "hello")
+	eq(w.Code, 201)
+	eq(calls, 2)
+}
+
+func TestIdempotency_InFlight(t *testing.T) {
+	store := middlewares.NewMemoryIdempotencyStore(time.Minute)
+	var inner josh.Resp
+	var hf josh.Handler
+	hf = func(r josh.Req) josh.Resp {
+		// Send a retry while the first request is still being handled.
+		req := httptest.NewRequest("POST", "http://example.com/foo", nil)
+		req.Header.Set("Idempotency-Key", "k1")
+		inner = middlewares.Idempotency(store, hf)(req)
+		return josh.Created("hi")
+	}
+	h := josh.Wrap(middlewares.Idempotency(store, hf))
+	req := httptest.NewRequest("POST", "http://example.com/foo", nil)
+	req.Header.Set("Idempotency-Key", "k1")
+	w := httptest.NewRecorder()
+	h(w, req)
+	eq(w.Code, 201)
+	eq(inner.Status, 409)
+}
+
+func TestIdempotency_ServerError(t *testing.T) {
+	calls := 0
+	hf := func(r josh.Req) josh.Resp {
+		calls += 1
+		return josh.InternalServerError(josh.Error{Detail: "oh no"})
+	}
+	store := middlewares.NewMemoryIdempotencyStore(time.Minute)
+	h := josh.Wrap(middlewares.Idempotency(store, hf))
+	for range 2 {
+		req := httptest.NewRequest("POST", "http://example.com/foo", nil)
+		req.Header.Set("Idempotency-Key", "k1")
+		w := httptest.NewRecorder()
+		h(w, req)
+		eq(w.Code, 500)
+	}
+	eq(calls, 2)
+}
+
+func TestIdempotency_OuterHeaders(t *testing.T) {
+	hf := func(r josh.Req) josh.Resp {
+		josh.SetHeader(r, "X-Call", "first")
+		return josh.Created("hi")
+	}
+	store := middlewares.NewMemoryIdempotencyStore(time.Minute)
+	h := josh.Wrap(middlewares.WithRequestID(middlewares.Idempotency(store, hf)))
+	send := func(id string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "http://example.com/foo", nil)
+		req.Header.Set("Idempotency-Key", "k1")
+		req.Header.Set("X-Request-ID", id)
+		w := httptest.NewRecorder()
+		h(w, req)
+		return w
+	}
+	w := send("first")
+	eq(w.Header().Get("X-Request-ID"), "first")
+	w = send("second")
+	eq(w.Code, 201)
+	eq(w.Header().Get("X-Call"), "first")
+	eq(w.Header().Get("X-Request-ID"), "second")
+}
+
+func TestIdempotency_BodyTooLarge(t *testing.T) {
+	calls := 0
+	hf := func(r josh.Req) josh.Resp {
+		calls += 1
+		return josh.Created("hi")
+	}
+	store := middlewares.NewMemoryIdempotencyStore(time.Minute)
+	h := josh.Wrap(middlewares.Idempotency(store, hf))
+	body := strings.Repeat("a", 1024*1024+1)
+	req := httptest.NewRequest("POST", "http://example.com/foo", strings.NewReader(body))
+	req.Header.Set("Idempotency-Key", "k1")
+	w := httptest.NewRecorder()
+	h(w, req)
+	eq(w.Code, 413)
+	eq(calls, 0)
+}
@@ -0,0 +1,199 @@
+package middlewares
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/orsinium-labs/josh"
+	"github.com/orsinium-labs/josh/headers"
+	"github.com/orsinium-labs/josh/statuses"
+)
+
+// RateLimitStatus is the result of [RateLimiter.Take].
+type RateLimitStatus struct {
+	// True if the request fits into the quota.
+	Allowed bool
+
+	// The total quota for the time window.
+	Limit int
+
+	// How many requests can still be made.
+	Remaining int
+
+	// How long until the quota is fully restored.
+	Reset time.Duration
+
+	// How long the client should wait before the next request is allowed.
+	//
+	// Zero if the request is allowed.
+	RetryAfter time.Duration
+}
+
+// RateLimiter tracks the quota for each key.
+//
+// The implementation must be safe for concurrent use.
+// See [NewTokenBucket] for an in-memory implementation.
+type RateLimiter interface {
+	// Take one request from the quota of the given key.
+	Take(key string) RateLimitStatus
+}
+
+// RateLimitKey identifies the client for [RateLimit].
+//
+// See [ByRemoteAddr] and [ByUser].
+type RateLimitKey func(josh.Req) string
+
+// Identify the client by the IP address of the connection.
+//
+// If the service is behind a proxy, you'll need your own [RateLimitKey]
+// that extracts the client address from a trusted header, like "X-Forwarded-For".
+func ByRemoteAddr(r josh.Req) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "addr:" + host
+}
+
+// Identify the client by the user set by [Auth].
+//
+// The key function must return a stable identifier of the user, like the user ID.
+// If there is no user in the request context, falls back to [ByRemoteAddr].
+func ByUser[U any](key func(U) string) RateLimitKey {
+	return func(r josh.Req) string {
+		user, err := josh.GetSingleton[U](r)
+		if err != nil {
+			return ByRemoteAddr(r)
+		}
+		return "user:" + key(user)
+	}
+}
+
+// Limit the number of requests each client can make.
+//
+// The "RateLimit-Limit", "RateLimit-Remaining", and "RateLimit-Reset" headers
+// are added to every response. If the quota is exceeded, 429 Too Many Requests
+// with the "Retry-After" header is returned without calling the handler.
+//
+// https://datatracker.ietf.org/doc/draft-ietf-httpapi-ratelimit-headers/
+func RateLimit(l RateLimiter, key RateLimitKey, h josh.Handler) josh.Handler {
+	return func(r josh.Req) josh.Resp {
+		status := l.Take(key(r))
+		josh.SetHeader(r, headers.RateLimitLimit, strconv.Itoa(status.Limit))
+		josh.SetHeader(r, headers.RateLimitRemaining, strconv.Itoa(status.Remaining))
+		josh.SetHeader(r, headers.RateLimitReset, seconds(status.Reset))
+		if !status.Allowed {
+			josh.SetHeader(r, headers.RetryAfter, seconds(status.RetryAfter))
+			return josh.Resp{
+				Status: statuses.TooManyRequests,
+				Errors: []josh.Error{{
+					Title:  "Too many requests",
+					Detail: fmt.Sprintf("Retry in %s seconds", seconds(status.RetryAfter)),
+				}},
+			}
+		}
+		return h(r)
+	}
+}
+
+// Format the duration as a whole number of seconds, rounding up.
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
+
+// TokenBucket is an in-memory [RateLimiter] implementing the token bucket algorithm.
+//
+// Each key has a bucket of "limit" tokens. Each request takes one token
+// and the bucket is continuously refilled at the rate of "limit" tokens per "window".
+// Allows short bursts of up to "limit" requests.
+//
+// Must be constructed using [NewTokenBucket].
+type TokenBucket struct {
+	mu        sync.Mutex
+	limit     int
+	window    time.Duration
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Create a new [TokenBucket] allowing "limit" requests per "window" for each key.
+//
+// Panics if the limit or the window is not positive.
+func NewTokenBucket(limit int, window time.Duration) *TokenBucket {
+	if limit <= 0 {
+		panic("middlewares: non-positive limit for NewTokenBucket")
+	}
+	if window <= 0 {
+		panic("middlewares: non-positive window for NewTokenBucket")
+	}
+	return &TokenBucket{
+		limit:     limit,
+		window:    window,
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// Take implements [RateLimiter].
+func (tb *TokenBucket) Take(key string) RateLimitStatus {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	now := time.Now()
+	tb.sweep(now)
+	b, found := tb.buckets[key]
+	if !found {
+		b = &bucket{tokens: float64(tb.limit), last: now}
+		tb.buckets[key] = b
+	}
+	b.tokens = tb.refill(b, now)
+	b.last = now
+
+	allowed := b.tokens >= 1
+	var retryAfter time.Duration
+	if allowed {
+		b.tokens -= 1
+	} else {
+		retryAfter = tb.timeFor(1 - b.tokens)
+	}
+	return RateLimitStatus{
+		Allowed:    allowed,
+		Limit:      tb.limit,
+		Remaining:  int(b.tokens),
+		Reset:      tb.timeFor(float64(tb.limit) - b.tokens),
+		RetryAfter: retryAfter,
+	}
+}
+
+// The number of tokens in the bucket at the given time.
+func (tb *TokenBucket) refill(b *bucket, now time.Time) float64 {
+	elapsed := now.Sub(b.last)
+	tokens := b.tokens + float64(tb.limit)*elapsed.Seconds()/tb.window.Seconds()
+	return min(tokens, float64(tb.limit))
+}
+
+// How long it takes to refill the given number of tokens.
+func (tb *TokenBucket) timeFor(tokens float64) time.Duration {
+	return time.Duration(tokens * float64(tb.window) / float64(tb.limit))
+}
+
+// Remove full buckets, at most once per window.
+func (tb *TokenBucket) sweep(now time.Time) {
+	if now.Sub(tb.lastSweep) < tb.window {
+		return
+	}
+	tb.lastSweep = now
+	for key, b := range tb.buckets {
+		if tb.refill(b, now) >= float64(tb.limit) {
+			delete(tb.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package middlewares_test
+
+import (
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/orsinium-labs/josh"
+	"github.com/orsinium-labs/josh/middlewares"
+)
+
+func TestRateLimit(t *testing.T) {
+	hf := func(r josh.Req) josh.Resp {
+		return josh.Ok("hi")
+	}
+	limiter := middlewares.NewTokenBucket(2, time.Minute)
+	h := josh.Wrap(middlewares.RateLimit(limiter, middlewares.ByRemoteAddr, hf))
+	send := func(addr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "http://example.com/foo", nil)
+		req.RemoteAddr = addr
+		w := httptest.NewRecorder()
+		h(w, req)
+		return w
+	}
+
+	w := send("10.0.0.1:1234")
+	eq(w.Code, 200)
+	eq(w.Header().Get("RateLimit-Limit"), "2")
+	eq(w.Header().Get("RateLimit-Remaining"), "1")
+	eq(w.Header().Get("RateLimit-Reset"), "30")
+
+	w = send("10.0.0.1:4321")
+	eq(w.Code, 200)
+	eq(w.Header().Get("RateLimit-Remaining"), "0")
+
+	w = send("10.0.0.1:1234")
+	eq(w.Code, 429)
+	eq(w.Header().Get("RateLimit-Remaining"), "0")
+	eq(w.Header().Get("Retry-After"), "30")
+
+	// Another client has its own quota.
+	w = send("10.0.0.2:1234")
+	eq(w.Code, 200)
+}
+
+func TestByUser(t *testing.T) {
+	type User struct {
+		ID   int
+		Name string
+	}
+	key := middlewares.ByUser(func(u *User) string { return strconv.Itoa(u.ID) })
+	req := httptest.NewRequest("GET", "http://example.com/foo", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	eq(key(req), "addr:10.0.0.1")
+	req = josh.Must(josh.WithSingleton(req, &User{ID: 42, Name: "aragorn"}))
+	eq(key(req), "user:42")
+}
+
+func TestNewTokenBucket_Invalid(t *testing.T) {
+	mustPanic := func(f func()) {
+		defer func() {
+			eq(recover() != nil, true)
+		}()
+		f()
+	}
+	mustPanic(func() { middlewares.NewTokenBucket(0, time.Minute) })
+	mustPanic(func() { middlewares.NewTokenBucket(10, 0) })
+}
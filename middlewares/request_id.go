@@ -0,0 +1,80 @@
+package middlewares
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+
+	"github.com/orsinium-labs/josh"
+	"github.com/orsinium-labs/josh/headers"
+)
+
+// RequestID is a unique identifier of the request set by [WithRequestID].
+//
+//	id := josh.Must(josh.GetSingleton[middlewares.RequestID](r))
+type RequestID string
+
+// Assign a unique ID to each request to correlate logs with client reports.
+//
+// The ID is taken from the "X-Request-ID" request header or generated if the header
+// is missing or invalid. The ID is:
+//
+//   - added into the request context as [RequestID] using [josh.WithSingleton],
+//   - added as "request-id" into the logger set by [WithLogger],
+//   - sent back to the client in the "X-Request-ID" response header,
+//   - used as the ID of all errors in the response that don't have an ID.
+//
+// To have the ID in all log records, put this middleware inside of [WithLogger].
+func WithRequestID(h josh.Handler) josh.Handler {
+	return func(r josh.Req) josh.Resp {
+		id := r.Header.Get(string(headers.XRequestID))
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		r = josh.Must(josh.WithSingleton(r, RequestID(id)))
+		logger, err := josh.GetSingleton[*slog.Logger](r)
+		if err == nil {
+			r = josh.ReplaceSingleton(r, logger.With("request-id", id))
+		}
+		josh.SetHeader(r, headers.XRequestID, id)
+
+		resp := h(r)
+		if len(resp.Errors) != 0 {
+			// Copy errors to not modify a slice that the handler might reuse.
+			errors := make([]josh.Error, len(resp.Errors))
+			copy(errors, resp.Errors)
+			for i := range errors {
+				if errors[i].ID == "" {
+					errors[i].ID = id
+				}
+			}
+			resp.Errors = errors
+		}
+		return resp
+	}
+}
+
+// Check that the request ID provided by the client is safe to log and send back.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z':
+		case c >= 'A' && c <= 'Z':
+		case c >= '0' && c <= '9':
+		case c == '-' || c == '_' || c == '.' || c == ':' || c == '/' || c == '+' || c == '=':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// Generate a random 128-bit hex-encoded request ID.
+func newRequestID() string {
+	var id [16]byte
+	_, _ = rand.Read(id[:])
+	return hex.EncodeToString(id[:])
+}
@@ -0,0 +1,49 @@
+package middlewares_test
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/orsinium-labs/josh"
+	"github.com/orsinium-labs/josh/middlewares"
+)
+
+func TestWithRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	hf := func(r josh.Req) josh.Resp {
+		id := josh.Must(josh.GetSingleton[middlewares.RequestID](r))
+		eq(id, "abc-123")
+		logger := josh.Must(josh.GetSingleton[*slog.Logger](r))
+		logger.Info("hello")
+		return josh.NotFound(josh.Error{Detail: "oh no"})
+	}
+	h := josh.Wrap(middlewares.WithLogger(logger, middlewares.WithRequestID(hf)))
+	req := httptest.NewRequest("GET", "http://example.com/foo", nil)
+	req.Header.Set("X-Request-ID", "abc-123")
+	w := httptest.NewRecorder()
+	h(w, req)
+	resp := w.Result()
+	eq(resp.StatusCode, 404)
+	eq(resp.Header.Get("X-Request-ID"), "abc-123")
+	body := josh.Must(io.ReadAll(resp.Body))
+	eq(string(body), `{"errors":[{"id":"abc-123","detail":"oh no"}]}`+"\n")
+	eq(strings.Contains(buf.String(), "request-id=abc-123"), true)
+}
+
+func TestWithRequestID_Generate(t *testing.T) {
+	hf := func(r josh.Req) josh.Resp {
+		return josh.NoContent()
+	}
+	h := josh.Wrap(middlewares.WithRequestID(hf))
+	req := httptest.NewRequest("GET", "http://example.com/foo", nil)
+	req.Header.Set("X-Request-ID", "bad\nid")
+	w := httptest.NewRecorder()
+	h(w, req)
+	id := w.Header().Get("X-Request-ID")
+	eq(len(id), 32)
+}
@@ -0,0 +1,53 @@
+package middlewares_test
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/orsinium-labs/josh"
+	"github.com/orsinium-labs/josh/headers"
+	"github.com/orsinium-labs/josh/middlewares"
+)
+
+func TestSecure(t *testing.T) {
+	hf := func(r josh.Req) josh.Resp {
+		josh.SetHeader(r, headers.ReferrerPolicy, "origin")
+		return josh.Ok("hi")
+	}
+	h := josh.Wrap(middlewares.Secure(middlewares.SecurityHeaders{
+		headers.CrossOriginResourcePolicy: "cross-origin",
+		headers.XFrameOptions:             "",
+	}, hf))
+
+	req := httptest.NewRequest("GET", "http://example.com/foo", nil)
+	w := httptest.NewRecorder()
+	h(w, req)
+	eq(w.Code, 200)
+	eq(w.Header().Get("X-Content-Type-Options"), "nosniff")
+	eq(w.Header().Get("Strict-Transport-Security"), "max-age=63072000; includeSubDomains")
+	eq(w.Header().Get("Content-Security-Policy"), "default-src 'none'; frame-ancestors 'none'")
+	eq(w.Header().Get("Cross-Origin-Resource-Policy"), "cross-origin")
+	eq(w.Header().Get("Referrer-Policy"), "origin")
+	eq(w.Header().Get("X-Frame-Options"), "")
+	eq(w.Header().Get("Cache-Control"), "")
+
+	req = httptest.NewRequest("GET", "http://example.com/foo", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	w = httptest.NewRecorder()
+	h(w, req)
+	eq(w.Header().Get("Cache-Control"), "no-store")
+}
+
+func TestSecure_APIKey(t *testing.T) {
+	hf := func(r josh.Req) josh.Resp {
+		return josh.Ok("hi")
+	}
+	v := middlewares.StaticAPIKeys(map[string]string{"secret": "aragorn"})
+	h := josh.Wrap(middlewares.APIKey("X-API-Key", "key", v, middlewares.Secure(nil, hf)))
+
+	req := httptest.NewRequest("GET", "http://example.com/foo?key=secret", nil)
+	w := httptest.NewRecorder()
+	h(w, req)
+	eq(w.Code, 200)
+	eq(w.Header().Get("Cache-Control"), "no-store")
+}
@@ -0,0 +1,187 @@
+package middlewares
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"maps"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/orsinium-labs/josh"
+	"github.com/orsinium-labs/josh/headers"
+	"github.com/orsinium-labs/josh/statuses"
+)
+
+// Limit how long the handler can take.
+//
+// The handler is called with a request context that has the given deadline.
+// If the client sent a shorter budget in the "Request-Timeout" header
+// (see [PropagateTimeout]), the shorter budget is used instead.
+//
+// If the handler doesn't return in time, the client gets 503 Service Unavailable
+// or, if the deadline came from the "Request-Timeout" header, 504 Gateway Timeout.
+// The handler keeps running in the background, [josh.Canceled] reports true for it,
+// and everything it returns or writes after the deadline is discarded.
+// The [http.ResponseWriter] in the handler context buffers the response,
+// so the middleware cannot be used for streaming responses.
+//
+// Make sure that the timeout is shorter than [http.Server.WriteTimeout],
+// otherwise the connection is closed before the error is sent.
+func Timeout(d time.Duration, h josh.Handler) josh.Handler {
+	return func(r josh.Req) josh.Resp {
+		budget := d
+		fromClient := false
+		clientBudget, err := parseTimeout(r.Header.Get(string(headers.RequestTimeout)), d)
+		if err == nil && clientBudget < budget {
+			budget = clientBudget
+			fromClient = true
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), budget)
+		defer cancel()
+
+		orig := josh.Must(josh.GetSingleton[http.ResponseWriter](r))
+		tw := &timeoutWriter{orig: orig, header: orig.Header().Clone()}
+		inner := josh.ReplaceSingleton[http.ResponseWriter](r.WithContext(ctx), tw)
+
+		done := make(chan josh.Resp, 1)
+		panicked := make(chan any, 1)
+		go func() {
+			defer func() {
+				p := recover()
+				if p != nil {
+					panicked <- p
+				}
+			}()
+			// Wrap makes [josh.SetHeader] write into the buffered headers.
+			// The response itself is passed to the outer middlewares.
+			josh.Wrap(func(r josh.Req) josh.Resp {
+				done <- h(r)
+				return josh.NoResponse()
+			})(tw, inner)
+		}()
+
+		select {
+		case resp := <-done:
+			tw.mu.Lock()
+			defer tw.mu.Unlock()
+			tw.finished = true
+			clear(orig.Header())
+			maps.Copy(orig.Header(), tw.header)
+			if tw.status != 0 || tw.body.Len() != 0 {
+				if tw.status != 0 {
+					orig.WriteHeader(tw.status)
+				}
+				_, _ = orig.Write(tw.body.Bytes())
+			}
+			return resp
+		case p := <-panicked:
+			// Re-panic in the request goroutine, so that [Recover] can catch it.
+			panic(p)
+		case <-ctx.Done():
+			tw.mu.Lock()
+			defer tw.mu.Unlock()
+			tw.finished = true
+			if josh.Canceled(r) {
+				// The client is gone, nobody will see the response.
+				return josh.NoResponse()
+			}
+			if fromClient {
+				return josh.Resp{
+					Status: statuses.GatewayTimeout,
+					Errors: []josh.Error{{
+						Title:  "Request timeout",
+						Detail: "The request was not handled within the budget from the Request-Timeout header",
+						Source: josh.SourceHeader(string(headers.RequestTimeout)),
+					}},
+				}
+			}
+			return josh.Resp{
+				Status: statuses.ServiceUnavailable,
+				Errors: []josh.Error{{
+					Title:  "Request timeout",
+					Detail: "The request was not handled in time",
+				}},
+			}
+		}
+	}
+}
+
+// Set the "Request-Timeout" header of an outgoing request to the time left until the context deadline.
+//
+// If the context has no deadline, nothing is set.
+// The downstream service can use [Timeout] to stop working on the request
+// when the caller is not waiting for it anymore.
+func PropagateTimeout(ctx context.Context, h http.Header) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return
+	}
+	left := max(time.Until(deadline), 0)
+	h.Set(string(headers.RequestTimeout), strconv.FormatFloat(left.Seconds(), 'f', 3, 64))
+}
+
+// Parse the number of seconds from the "Request-Timeout" header.
+//
+// Only positive finite values are accepted. Values above the maximum are clamped.
+func parseTimeout(raw string, maximum time.Duration) (time.Duration, error) {
+	seconds, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(seconds) || math.IsInf(seconds, 0) || seconds <= 0 {
+		return 0, errors.New("timeout must be a positive number")
+	}
+	if seconds >= maximum.Seconds() {
+		return maximum, nil
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// A [http.ResponseWriter] buffering the response until the handler finishes in time.
+//
+// Writes after the timeout are discarded.
+type timeoutWriter struct {
+	orig     http.ResponseWriter
+	mu       sync.Mutex
+	header   http.Header
+	body     bytes.Buffer
+	status   int
+	finished bool
+}
+
+func (w *timeoutWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *timeoutWriter) WriteHeader(status int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.finished || w.status != 0 {
+		return
+	}
+	w.status = status
+}
+
+func (w *timeoutWriter) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.finished {
+		return 0, http.ErrHandlerTimeout
+	}
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.body.Write(b)
+}
+
+// Unwrap is used by [http.ResponseController] to access the original writer.
+//
+// Flushing or hijacking the connection bypasses the buffer, so the handler
+// becomes responsible for the whole response.
+func (w *timeoutWriter) Unwrap() http.ResponseWriter {
+	return w.orig
+}
@@ -0,0 +1,115 @@
+package middlewares_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/orsinium-labs/josh"
+	"github.com/orsinium-labs/josh/middlewares"
+)
+
+func TestTimeout(t *testing.T) {
+	finished := make(chan bool, 1)
+	hf := func(r josh.Req) josh.Resp {
+		josh.SetHeader(r, "X-Test", "hello")
+		if r.URL.Path == "/slow" {
+			<-r.Context().Done()
+			finished <- josh.Canceled(r)
+		}
+		return josh.Ok("hi")
+	}
+	h := josh.Wrap(middlewares.Timeout(10*time.Millisecond, hf))
+
+	req := httptest.NewRequest("GET", "http://example.com/fast", nil)
+	w := httptest.NewRecorder()
+	h(w, req)
+	eq(w.Code, 200)
+	eq(w.Header().Get("X-Test"), "hello")
+
+	req = httptest.NewRequest("GET", "http://example.com/slow", nil)
+	w = httptest.NewRecorder()
+	h(w, req)
+	eq(w.Code, 503)
+	eq(w.Header().Get("X-Test"), "")
+	eq(<-finished, true)
+
+	req = httptest.NewRequest("GET", "http://example.com/slow", nil)
+	req.Header.Set("Request-Timeout", "0.001")
+	w = httptest.NewRecorder()
+	h(w, req)
+	eq(w.Code, 504)
+	<-finished
+}
+
+func TestTimeout_DirectWrite(t *testing.T) {
+	hh := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(203)
+		_, _ = w.Write([]byte("hi"))
+	}
+	h := josh.Wrap(middlewares.Timeout(time.Second, josh.Unwrap(hh)))
+	req := httptest.NewRequest("GET", "http://example.com/foo", nil)
+	w := httptest.NewRecorder()
+	h(w, req)
+	eq(w.Code, 203)
+	eq(w.Body.String(), "hi")
+}
+
+func TestTimeout_Panic(t *testing.T) {
+	hf := func(r josh.Req) josh.Resp {
+		panic("oh no")
+	}
+	h := josh.Wrap(middlewares.Recover(middlewares.Timeout(time.Second, hf)))
+	req := httptest.NewRequest("GET", "http://example.com/foo", nil)
+	w := httptest.NewRecorder()
+	h(w, req)
+	eq(w.Code, 500)
+}
+
+func TestPropagateTimeout(t *testing.T) {
+	h := http.Header{}
+	middlewares.PropagateTimeout(context.Background(), h)
+	eq(h.Get("Request-Timeout"), "")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	middlewares.PropagateTimeout(ctx, h)
+	budget := josh.Must(strconv.ParseFloat(h.Get("Request-Timeout"), 64))
+	eq(budget > 1.5 && budget <= 2, true)
+}
+
+func TestTimeout_InvalidHeader(t *testing.T) {
+	hf := func(r josh.Req) josh.Resp {
+		deadline, _ := r.Context().Deadline()
+		if time.Until(deadline) < 500*time.Millisecond {
+			return josh.BadRequest(josh.Error{Detail: "budget is too short"})
+		}
+		return josh.Ok("hi")
+	}
+	h := josh.Wrap(middlewares.Timeout(time.Second, hf))
+	for _, value := range []string{"NaN", "+Inf", "-Inf", "1e20", "0", "-1", "oops"} {
+		req := httptest.NewRequest("GET", "http://example.com/foo", nil)
+		req.Header.Set("Request-Timeout", value)
+		w := httptest.NewRecorder()
+		h(w, req)
+		if w.Code != 200 {
+			t.Fatalf("Request-Timeout: %s: got status %d", value, w.Code)
+		}
+	}
+}
+
+func TestTimeout_ResponseController(t *testing.T) {
+	hh := func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("hi"))
+		eq(http.NewResponseController(w).Flush(), nil)
+	}
+	h := josh.Wrap(middlewares.Timeout(time.Second, josh.Unwrap(hh)))
+	req := httptest.NewRequest("GET", "http://example.com/foo", nil)
+	w := httptest.NewRecorder()
+	h(w, req)
+	eq(w.Flushed, true)
+	eq(w.Body.String(), "hi")
+}
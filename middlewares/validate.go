@@ -0,0 +1,656 @@
+package middlewares
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/orsinium-labs/josh"
+)
+
+// Violation is a JSON:API rule broken by a document. Returned by [ValidateDocument].
+type Violation struct {
+	// A JSON Pointer (RFC6901) to the invalid value in the document.
+	Pointer string
+
+	// The description of the violated rule.
+	Message string
+}
+
+func (v Violation) String() string {
+	if v.Pointer == "" {
+		return "/: " + v.Message
+	}
+	return v.Pointer + ": " + v.Message
+}
+
+// Check the response of the handler using [ValidateDocument] and log violations.
+//
+// The response is passed through unchanged. It's meant to be used in development
+// to catch invalid links, relationships, and included resources
+// that handlers put into [josh.Resp]. The logger is taken from the request
+// context (see [WithLogger]). If there is none, the default logger is used.
+//
+// In tests, use [joshtest.Response.ExpectValid] instead.
+//
+// [joshtest.Response.ExpectValid]: https://pkg.go.dev/github.com/orsinium-labs/josh/joshtest#Response.ExpectValid
+func ValidateResponses(h josh.Handler) josh.Handler {
+	return func(r josh.Req) josh.Resp {
+		resp := h(r)
+		if resp.Data == nil && resp.Errors == nil && resp.Meta == nil {
+			return resp
+		}
+		logger, _ := josh.GetSingleton[*slog.Logger](r)
+		if logger == nil {
+			logger = slog.Default()
+		}
+		raw, err := json.Marshal(resp)
+		if err != nil {
+			logger.ErrorContext(r.Context(), "cannot encode response", "error", err)
+			return resp
+		}
+		for _, v := range ValidateDocument(raw) {
+			logger.WarnContext(
+				r.Context(),
+				"invalid JSON:API response",
+				"pointer", v.Pointer,
+				"violation", v.Message,
+				"path", r.URL.Path,
+				"method", r.Method,
+			)
+		}
+		return resp
+	}
+}
+
+// Validate the JSON:API 1.1 document.
+//
+// Checks the top-level members, resource objects and their identity,
+// uniqueness of resources in included, full linkage, relationships,
+// links, errors, and member names. Returns nil if the document is valid.
+//
+// https://jsonapi.org/format/1.1/
+func ValidateDocument(doc []byte) []Violation {
+	var root any
+	err := json.Unmarshal(doc, &root)
+	if err != nil {
+		return []Violation{{Message: fmt.Sprintf("invalid JSON: %v", err)}}
+	}
+	v := &validator{
+		resources: make(map[resourceKey]string),
+		edges:     make(map[resourceKey][]resourceKey),
+	}
+	v.document(root)
+	return v.violations
+}
+
+// The identity of a resource.
+type resourceKey struct {
+	typ string
+	id  string
+}
+
+type validator struct {
+	violations []Violation
+	// Pointers to resource objects in data and included.
+	resources map[resourceKey]string
+	// Resources in primary data and the ones their relationships point to.
+	roots []resourceKey
+	// Resources that relationships of other resources point to.
+	edges map[resourceKey][]resourceKey
+	// The resource which relationships are being checked.
+	owner    resourceKey
+	hasOwner bool
+	primary  bool
+}
+
+func (v *validator) add(ptr, format string, args ...any) {
+	v.violations = append(v.violations, Violation{
+		Pointer: ptr,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// Validate the top-level document.
+func (v *validator) document(root any) {
+	doc, ok := root.(map[string]any)
+	if !ok {
+		v.add("", "document must be an object")
+		return
+	}
+	_, hasData := doc["data"]
+	_, hasErrors := doc["errors"]
+	_, hasMeta := doc["meta"]
+	_, hasIncluded := doc["included"]
+	switch {
+	case !hasData && !hasErrors && !hasMeta:
+		v.add("", "document must contain data, errors, or meta")
+	case hasData && hasErrors:
+		v.add("", "document must not contain both data and errors")
+	}
+	if hasIncluded && !hasData {
+		v.add("/included", "included must not be present without data")
+	}
+
+	for _, key := range sortedKeys(doc) {
+		ptr := "/" + escapePointer(key)
+		val := doc[key]
+		switch key {
+		case "data":
+			v.primaryData(ptr, val)
+		case "included":
+			v.included(ptr, val)
+		case "errors":
+			v.errors(ptr, val)
+		case "meta":
+			v.meta(ptr, val)
+		case "links":
+			v.links(ptr, val)
+		case "jsonapi":
+			v.jsonapi(ptr, val)
+		default:
+			v.extraMember(ptr, key)
+		}
+	}
+
+	// Full linkage: every included resource must be reachable from primary data.
+	reachable := v.reachable()
+	included, _ := doc["included"].([]any)
+	for i, item := range included {
+		res, _ := item.(map[string]any)
+		key, ok := identity(res)
+		if ok && !reachable[key] {
+			v.add(fmt.Sprintf("/included/%d", i), "included resource is not reachable from primary data through relationships")
+		}
+	}
+}
+
+// Find the resources reachable from primary data through relationships.
+func (v *validator) reachable() map[resourceKey]bool {
+	seen := make(map[resourceKey]bool)
+	queue := slices.Clone(v.roots)
+	for len(queue) > 0 {
+		key := queue[0]
+		queue = queue[1:]
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		queue = append(queue, v.edges[key]...)
+	}
+	return seen
+}
+
+// Validate the primary data: null, a resource object, or an array of them.
+func (v *validator) primaryData(ptr string, val any) {
+	switch val := val.(type) {
+	case nil:
+	case map[string]any:
+		v.resource(ptr, val, true)
+	case []any:
+		for i, item := range val {
+			v.resource(fmt.Sprintf("%s/%d", ptr, i), item, true)
+		}
+	default:
+		v.add(ptr, "data must be null, a resource object, or an array of resource objects")
+	}
+}
+
+// Validate the array of included resource objects.
+func (v *validator) included(ptr string, val any) {
+	items, ok := val.([]any)
+	if !ok {
+		v.add(ptr, "included must be an array of resource objects")
+		return
+	}
+	for i, item := range items {
+		v.resource(fmt.Sprintf("%s/%d", ptr, i), item, false)
+	}
+}
+
+// Validate a resource object in primary data or included.
+//
+// Resource identifier objects in primary data are valid resource objects too.
+func (v *validator) resource(ptr string, val any, primary bool) {
+	res, ok := val.(map[string]any)
+	if !ok {
+		v.add(ptr, "resource must be an object")
+		return
+	}
+	key, ok := v.identity(ptr, res, false)
+	if ok {
+		prev, seen := v.resources[key]
+		if seen {
+			v.add(ptr, "duplicate resource with type %q and id %q, also at %s", key.typ, key.id, prev)
+		} else {
+			v.resources[key] = ptr
+		}
+		if primary {
+			v.roots = append(v.roots, key)
+		}
+	}
+	v.owner, v.hasOwner, v.primary = key, ok, primary
+
+	attrs, _ := res["attributes"].(map[string]any)
+	for _, name := range sortedKeys(res) {
+		childPtr := ptr + "/" + escapePointer(name)
+		child := res[name]
+		switch name {
+		case "type", "id", "lid":
+		case "attributes":
+			v.attributes(childPtr, child)
+		case "relationships":
+			v.relationships(childPtr, child, attrs)
+		case "links":
+			v.links(childPtr, child)
+		case "meta":
+			v.meta(childPtr, child)
+		default:
+			v.extraMember(childPtr, name)
+		}
+	}
+}
+
+// Check the "type" and "id" members of a resource object or identifier.
+//
+// Identifiers inside of relationships can have "lid" instead of "id".
+func (v *validator) identity(ptr string, res map[string]any, allowLID bool) (resourceKey, bool) {
+	ok := true
+	typ, isString := res["type"].(string)
+	if !isString || typ == "" {
+		v.add(ptr+"/type", "type must be a non-empty string")
+		ok = false
+	}
+	lid, hasLID := res["lid"]
+	if _, isString := lid.(string); hasLID && !isString {
+		v.add(ptr+"/lid", "lid must be a string")
+	}
+	id, hasID := res["id"]
+	switch {
+	case hasID:
+		if _, isString := id.(string); !isString {
+			v.add(ptr+"/id", "id must be a string")
+			ok = false
+		}
+	case hasLID && allowLID:
+		return resourceKey{}, false
+	default:
+		v.add(ptr, "resource must have an id")
+		ok = false
+	}
+	if !ok {
+		return resourceKey{}, false
+	}
+	return resourceKey{typ: typ, id: id.(string)}, true
+}
+
+// Validate the attributes object of a resource.
+func (v *validator) attributes(ptr string, val any) {
+	attrs, ok := val.(map[string]any)
+	if !ok {
+		v.add(ptr, "attributes must be an object")
+		return
+	}
+	for _, name := range sortedKeys(attrs) {
+		childPtr := ptr + "/" + escapePointer(name)
+		if name == "id" || name == "type" {
+			v.add(childPtr, "attribute must not be named %q", name)
+		}
+		v.memberName(childPtr, name)
+		v.attributeValue(childPtr, attrs[name])
+	}
+}
+
+// Check member names in a complex attribute value.
+func (v *validator) attributeValue(ptr string, val any) {
+	switch val := val.(type) {
+	case map[string]any:
+		for _, name := range sortedKeys(val) {
+			childPtr := ptr + "/" + escapePointer(name)
+			if name == "relationships" || name == "links" {
+				v.add(childPtr, "attribute value must not contain %q", name)
+			}
+			v.memberName(childPtr, name)
+			v.attributeValue(childPtr, val[name])
+		}
+	case []any:
+		for i, item := range val {
+			v.attributeValue(fmt.Sprintf("%s/%d", ptr, i), item)
+		}
+	}
+}
+
+// Validate the relationships object of a resource.
+func (v *validator) relationships(ptr string, val any, attrs map[string]any) {
+	rels, ok := val.(map[string]any)
+	if !ok {
+		v.add(ptr, "relationships must be an object")
+		return
+	}
+	for _, name := range sortedKeys(rels) {
+		childPtr := ptr + "/" + escapePointer(name)
+		if name == "id" || name == "type" {
+			v.add(childPtr, "relationship must not be named %q", name)
+		}
+		if _, clash := attrs[name]; clash {
+			v.add(childPtr, "relationship must not have the same name as an attribute")
+		}
+		v.memberName(childPtr, name)
+		v.relationship(childPtr, rels[name])
+	}
+}
+
+// Validate a relationship object.
+func (v *validator) relationship(ptr string, val any) {
+	rel, ok := val.(map[string]any)
+	if !ok {
+		v.add(ptr, "relationship must be an object")
+		return
+	}
+	_, hasLinks := rel["links"]
+	_, hasData := rel["data"]
+	_, hasMeta := rel["meta"]
+	if !hasLinks && !hasData && !hasMeta {
+		v.add(ptr, "relationship must contain links, data, or meta")
+	}
+	for _, name := range sortedKeys(rel) {
+		childPtr := ptr + "/" + escapePointer(name)
+		child := rel[name]
+		switch name {
+		case "data":
+			v.linkage(childPtr, child)
+		case "links":
+			links, _ := child.(map[string]any)
+			_, hasSelf := links["self"]
+			_, hasRelated := links["related"]
+			if links != nil && !hasSelf && !hasRelated {
+				v.add(childPtr, "relationship links must contain self or related")
+			}
+			v.links(childPtr, child)
+		case "meta":
+			v.meta(childPtr, child)
+		default:
+			v.extraMember(childPtr, name)
+		}
+	}
+}
+
+// Validate the resource linkage: null, an identifier, or an array of identifiers.
+func (v *validator) linkage(ptr string, val any) {
+	switch val := val.(type) {
+	case nil:
+	case map[string]any:
+		v.identifier(ptr, val)
+	case []any:
+		for i, item := range val {
+			v.identifier(fmt.Sprintf("%s/%d", ptr, i), item)
+		}
+	default:
+		v.add(ptr, "relationship data must be null, a resource identifier, or an array of them")
+	}
+}
+
+// Validate a resource identifier object and link the resource to its owner.
+func (v *validator) identifier(ptr string, val any) {
+	ident, ok := val.(map[string]any)
+	if !ok {
+		v.add(ptr, "resource identifier must be an object")
+		return
+	}
+	key, ok := v.identity(ptr, ident, true)
+	switch {
+	case !ok:
+	case v.primary:
+		// Relationships of primary data are reachable even if the resource itself is invalid.
+		v.roots = append(v.roots, key)
+	case v.hasOwner:
+		v.edges[v.owner] = append(v.edges[v.owner], key)
+	}
+	for _, name := range sortedKeys(ident) {
+		childPtr := ptr + "/" + escapePointer(name)
+		switch name {
+		case "type", "id", "lid":
+		case "meta":
+			v.meta(childPtr, ident[name])
+		default:
+			v.extraMember(childPtr, name)
+		}
+	}
+}
+
+// Validate a links object.
+func (v *validator) links(ptr string, val any) {
+	links, ok := val.(map[string]any)
+	if !ok {
+		v.add(ptr, "links must be an object")
+		return
+	}
+	for _, name := range sortedKeys(links) {
+		childPtr := ptr + "/" + escapePointer(name)
+		v.memberName(childPtr, name)
+		v.link(childPtr, links[name])
+	}
+}
+
+// Validate a link: null, a URI string, or a link object.
+func (v *validator) link(ptr string, val any) {
+	switch val := val.(type) {
+	case nil, string:
+	case map[string]any:
+		if _, ok := val["href"].(string); !ok {
+			v.add(ptr+"/href", "link object must have href string")
+		}
+		for _, name := range sortedKeys(val) {
+			childPtr := ptr + "/" + escapePointer(name)
+			child := val[name]
+			switch name {
+			case "href":
+			case "rel", "title", "type":
+				v.stringMember(childPtr, child)
+			case "hreflang":
+				if _, ok := child.(string); !ok && !isStringArray(child) {
+					v.add(childPtr, "hreflang must be a string or an array of strings")
+				}
+			case "describedby":
+				v.link(childPtr, child)
+			case "meta":
+				v.meta(childPtr, child)
+			default:
+				v.extraMember(childPtr, name)
+			}
+		}
+	default:
+		v.add(ptr, "link must be null, a string, or a link object")
+	}
+}
+
+// Validate the array of error objects.
+func (v *validator) errors(ptr string, val any) {
+	errs, ok := val.([]any)
+	if !ok {
+		v.add(ptr, "errors must be an array of error objects")
+		return
+	}
+	for i, item := range errs {
+		itemPtr := fmt.Sprintf("%s/%d", ptr, i)
+		obj, ok := item.(map[string]any)
+		if !ok {
+			v.add(itemPtr, "error must be an object")
+			continue
+		}
+		for _, name := range sortedKeys(obj) {
+			childPtr := itemPtr + "/" + escapePointer(name)
+			child := obj[name]
+			switch name {
+			case "id", "status", "code", "title", "detail":
+				v.stringMember(childPtr, child)
+			case "links":
+				v.links(childPtr, child)
+			case "source":
+				v.errorSource(childPtr, child)
+			case "meta":
+				v.meta(childPtr, child)
+			default:
+				v.extraMember(childPtr, name)
+			}
+		}
+	}
+}
+
+// Validate the source object of an error.
+func (v *validator) errorSource(ptr string, val any) {
+	src, ok := val.(map[string]any)
+	if !ok {
+		v.add(ptr, "source must be an object")
+		return
+	}
+	for _, name := range sortedKeys(src) {
+		childPtr := ptr + "/" + escapePointer(name)
+		switch name {
+		case "pointer":
+			p, ok := src[name].(string)
+			if !ok || p != "" && p[0] != '/' {
+				v.add(childPtr, "pointer must be a JSON Pointer string")
+			}
+		case "parameter", "header":
+			v.stringMember(childPtr, src[name])
+		default:
+			v.extraMember(childPtr, name)
+		}
+	}
+}
+
+// Validate the jsonapi object.
+func (v *validator) jsonapi(ptr string, val any) {
+	obj, ok := val.(map[string]any)
+	if !ok {
+		v.add(ptr, "jsonapi must be an object")
+		return
+	}
+	for _, name := range sortedKeys(obj) {
+		childPtr := ptr + "/" + escapePointer(name)
+		child := obj[name]
+		switch name {
+		case "version":
+			v.stringMember(childPtr, child)
+		case "ext", "profile":
+			if !isStringArray(child) {
+				v.add(childPtr, "%s must be an array of strings", name)
+			}
+		case "meta":
+			v.meta(childPtr, child)
+		default:
+			v.extraMember(childPtr, name)
+		}
+	}
+}
+
+// Validate a meta object and member names inside of it.
+func (v *validator) meta(ptr string, val any) {
+	obj, ok := val.(map[string]any)
+	if !ok {
+		v.add(ptr, "meta must be an object")
+		return
+	}
+	v.names(ptr, obj)
+}
+
+// Check member names of the object and all nested objects.
+func (v *validator) names(ptr string, val any) {
+	switch val := val.(type) {
+	case map[string]any:
+		for _, name := range sortedKeys(val) {
+			childPtr := ptr + "/" + escapePointer(name)
+			v.memberName(childPtr, name)
+			v.names(childPtr, val[name])
+		}
+	case []any:
+		for i, item := range val {
+			v.names(fmt.Sprintf("%s/%d", ptr, i), item)
+		}
+	}
+}
+
+func (v *validator) stringMember(ptr string, val any) {
+	if _, ok := val.(string); !ok {
+		v.add(ptr, "must be a string")
+	}
+}
+
+// Check a member that is not defined by the spec.
+//
+// Only @-members and extension members ("namespace:member") are allowed.
+func (v *validator) extraMember(ptr, name string) {
+	if strings.HasPrefix(name, "@") {
+		v.memberName(ptr, name)
+		return
+	}
+	ns, member, found := strings.Cut(name, ":")
+	if found && validMemberName(ns) && validMemberName(member) {
+		return
+	}
+	v.add(ptr, "unknown member %q", name)
+}
+
+func (v *validator) memberName(ptr, name string) {
+	if !validMemberName(strings.TrimPrefix(name, "@")) {
+		v.add(ptr, "invalid member name %q", name)
+	}
+}
+
+// Check the member name rules.
+//
+// https://jsonapi.org/format/1.1/#document-member-names
+func validMemberName(name string) bool {
+	runes := []rune(name)
+	if len(runes) == 0 {
+		return false
+	}
+	for i, c := range runes {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c >= 0x80 && c != 0xFFFF:
+		case c == '-' || c == '_' || c == ' ':
+			if i == 0 || i == len(runes)-1 {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// Get the identity of a valid resource object without reporting violations.
+func identity(res map[string]any) (resourceKey, bool) {
+	typ, ok1 := res["type"].(string)
+	id, ok2 := res["id"].(string)
+	return resourceKey{typ: typ, id: id}, ok1 && ok2 && typ != ""
+}
+
+func isStringArray(val any) bool {
+	items, ok := val.([]any)
+	if !ok {
+		return false
+	}
+	for _, item := range items {
+		if _, ok := item.(string); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Escape the object key to be used as a JSON Pointer segment.
+func escapePointer(key string) string {
+	key = strings.ReplaceAll(key, "~", "~0")
+	return strings.ReplaceAll(key, "/", "~1")
+}
+
+// Get the object keys in a stable order, so that violations are reported deterministically.
+func sortedKeys(obj map[string]any) []string {
+	return slices.Sorted(maps.Keys(obj))
+}
@@ -0,0 +1,140 @@
+package middlewares_test
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/orsinium-labs/josh"
+	"github.com/orsinium-labs/josh/middlewares"
+)
+
+func TestValidateDocument_Valid(t *testing.T) {
+	docs := []string{
+		`{"data": null}`,
+		`{"data": []}`,
+		`{"meta": {"total": 0}}`,
+		`{"errors": [{"code": "invalid", "source": {"pointer": "/data/id"}}]}`,
+		`{"data": {"type": "user", "id": "1", "attributes": {"first-name": "Frodo", "home": {"name": "Shire"}}}}`,
+		`{
+			"data": [{
+				"type": "article", "id": "1",
+				"relationships": {
+					"author": {"data": {"type": "user", "id": "9"}, "links": {"related": "/articles/1/author"}},
+					"tags": {"data": [{"type": "tag", "lid": "new"}]}
+				},
+				"links": {"self": {"href": "/articles/1", "hreflang": ["en", "nl"], "describedby": "/schema"}}
+			}],
+			"included": [{
+				"type": "user", "id": "9",
+				"relationships": {"friends": {"data": [{"type": "user", "id": "10"}]}}
+			}, {"type": "user", "id": "10"}],
+			"links": {"self": "/articles", "next": null},
+			"jsonapi": {"version": "1.1", "ext": ["https://example.com/ext"]},
+			"@context": "x",
+			"atomic:results": []
+		}`,
+	}
+	for _, doc := range docs {
+		violations := middlewares.ValidateDocument([]byte(doc))
+		if len(violations) != 0 {
+			t.Errorf("%s\n%v", doc, violations)
+		}
+	}
+}
+
+func TestValidateDocument_Invalid(t *testing.T) {
+	cases := []struct {
+		doc  string
+		want string
+	}{
+		{`[]`, `/: document must be an object`},
+		{`{"links": {}}`, `/: document must contain data, errors, or meta`},
+		{`{"data": null, "errors": []}`, `/: document must not contain both data and errors`},
+		{`{"meta": {}, "included": []}`, `/included: included must not be present without data`},
+		{`{"data": null, "version": 1}`, `/version: unknown member "version"`},
+		{`{"data": "hi"}`, `/data: data must be null, a resource object, or an array of resource objects`},
+		{`{"data": {"id": "1"}}`, `/data/type: type must be a non-empty string`},
+		{`{"data": {"type": "user"}}`, `/data: resource must have an id`},
+		{`{"data": [{"type": "user", "id": 1}]}`, `/data/0/id: id must be a string`},
+		{`{"data": {"type": "user", "id": "1", "name": "Sam"}}`, `/data/name: unknown member "name"`},
+		{`{"data": {"type": "user", "id": "1", "attributes": "Sam"}}`, `/data/attributes: attributes must be an object`},
+		{`{"data": {"type": "user", "id": "1", "attributes": {"id": "1"}}}`, `/data/attributes/id: attribute must not be named "id"`},
+		{`{"data": {"type": "user", "id": "1", "attributes": {"a/b": 1}}}`, `/data/attributes/a~1b: invalid member name "a/b"`},
+		{`{"data": {"type": "user", "id": "1", "attributes": {"x": [{"_y": 1}]}}}`, `/data/attributes/x/0/_y: invalid member name "_y"`},
+		{`{"data": {"type": "user", "id": "1", "attributes": {"x": {"links": {}}}}}`, `/data/attributes/x/links: attribute value must not contain "links"`},
+		{`{"data": {"type": "user", "id": "1", "attributes": {"pet": 1}, "relationships": {"pet": {"meta": {}}}}}`, `/data/relationships/pet: relationship must not have the same name as an attribute`},
+		{`{"data": {"type": "user", "id": "1", "relationships": {"pet": {}}}}`, `/data/relationships/pet: relationship must contain links, data, or meta`},
+		{`{"data": {"type": "user", "id": "1", "relationships": {"pet": {"links": {"next": "/x"}}}}}`, `/data/relationships/pet/links: relationship links must contain self or related`},
+		{`{"data": {"type": "user", "id": "1", "relationships": {"pet": {"data": "dog"}}}}`, `/data/relationships/pet/data: relationship data must be null, a resource identifier, or an array of them`},
+		{`{"data": {"type": "user", "id": "1", "relationships": {"pet": {"data": {"type": "pet", "id": "1", "name": "x"}}}}}`, `/data/relationships/pet/data/name: unknown member "name"`},
+		{`{"data": [{"type": "user", "id": "1"}], "included": [{"type": "user", "id": "1"}]}`, `/included/0: duplicate resource with type "user" and id "1", also at /data/0`},
+		{`{"data": [], "included": [{"type": "user", "id": "1"}]}`, `/included/0: included resource is not reachable from primary data through relationships`},
+		{`{"data": {"type": "user", "id": "1"}, "included": [{"type": "user", "id": "1"}]}`, `/included/0: duplicate resource with type "user" and id "1", also at /data`},
+		{`{"data": null, "links": {"self": 1}}`, `/links/self: link must be null, a string, or a link object`},
+		{`{"data": null, "links": {"self": {"title": "x"}}}`, `/links/self/href: link object must have href string`},
+		{`{"data": null, "links": {"self": {"href": "/", "hreflang": 1}}}`, `/links/self/hreflang: hreflang must be a string or an array of strings`},
+		{`{"errors": {}}`, `/errors: errors must be an array of error objects`},
+		{`{"errors": [{"status": 400}]}`, `/errors/0/status: must be a string`},
+		{`{"errors": [{"source": {"pointer": "data"}}]}`, `/errors/0/source/pointer: pointer must be a JSON Pointer string`},
+		{`{"meta": []}`, `/meta: meta must be an object`},
+		{`{"meta": {"-total": 1}}`, `/meta/-total: invalid member name "-total"`},
+		{`{"meta": {}, "jsonapi": {"ext": "x"}}`, `/jsonapi/ext: ext must be an array of strings`},
+	}
+	for _, c := range cases {
+		violations := middlewares.ValidateDocument([]byte(c.doc))
+		if len(violations) != 1 || violations[0].String() != c.want {
+			t.Errorf("%s\ngot:  %v\nwant: %s", c.doc, violations, c.want)
+		}
+	}
+}
+
+func TestValidateDocument_Cycle(t *testing.T) {
+	// The included resources point only at each other.
+	doc := `{
+		"data": {"type": "user", "id": "1"},
+		"included": [
+			{"type": "user", "id": "2", "relationships": {"friend": {"data": {"type": "user", "id": "3"}}}},
+			{"type": "user", "id": "3", "relationships": {"friend": {"data": {"type": "user", "id": "2"}}}}
+		]
+	}`
+	violations := middlewares.ValidateDocument([]byte(doc))
+	eq(len(violations), 2)
+	eq(violations[0].String(), "/included/0: included resource is not reachable from primary data through relationships")
+	eq(violations[1].Pointer, "/included/1")
+
+	// Reachable through another included resource.
+	doc = `{
+		"data": {"type": "user", "id": "1", "relationships": {"friend": {"data": {"type": "user", "id": "2"}}}},
+		"included": [
+			{"type": "user", "id": "2", "relationships": {"friend": {"data": {"type": "user", "id": "3"}}}},
+			{"type": "user", "id": "3", "relationships": {"friend": {"data": {"type": "user", "id": "2"}}}}
+		]
+	}`
+	eq(len(middlewares.ValidateDocument([]byte(doc))), 0)
+}
+
+func TestValidateResponses(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	send := func(h josh.Handler) string {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/", nil)
+		w := httptest.NewRecorder()
+		josh.Wrap(middlewares.WithLogger(logger, middlewares.ValidateResponses(h)))(w, req)
+		eq(w.Code, 200)
+		return strings.TrimSpace(w.Body.String())
+	}
+
+	body := send(func(r josh.Req) josh.Resp { return josh.Ok("hello") })
+	eq(body, `{"data":"hello"}`)
+	eq(strings.Contains(buf.String(), `pointer=/data violation="data must be null`), true)
+
+	buf.Reset()
+	send(func(r josh.Req) josh.Resp {
+		return josh.Ok(josh.Data[string]{ID: "1", Type: "user"})
+	})
+	eq(buf.String(), "")
+}
@@ -98,3 +98,62 @@ type Endpoint struct {
 	// https://developer.mozilla.org/en-US/docs/Web/HTTP/Methods/PATCH
 	PATCH http.HandlerFunc
 }
+
+// Middleware wraps a handler to run some code before and/or after it.
+//
+// Use [Lift] to convert a josh middleware into [Middleware].
+type Middleware func(http.HandlerFunc) http.HandlerFunc
+
+// Convert a josh middleware into [Middleware].
+//
+// The wrapped [http.HandlerFunc] writes the response itself, so the middleware
+// sees [NoResponse] returned from the next handler. Middlewares that inspect
+// or modify the [Resp] (like the access log or idempotency) should wrap
+// the [Handler] directly instead.
+//
+//	auth := josh.Lift(func(h josh.Handler) josh.Handler {
+//		return middlewares.Auth(validator, h)
+//	})
+func Lift(mw func(Handler) Handler) Middleware {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return Wrap(mw(Unwrap(next)))
+	}
+}
+
+// Wrap all handlers of the endpoint in the given middlewares.
+//
+// The first middleware is the outermost one, so it runs first.
+func (e Endpoint) With(mws ...Middleware) Endpoint {
+	wrap := func(h http.HandlerFunc) http.HandlerFunc {
+		if h == nil {
+			return nil
+		}
+		for i := len(mws) - 1; i >= 0; i-- {
+			h = mws[i](h)
+		}
+		return h
+	}
+	return Endpoint{
+		GET:     wrap(e.GET),
+		HEAD:    wrap(e.HEAD),
+		POST:    wrap(e.POST),
+		PUT:     wrap(e.PUT),
+		DELETE:  wrap(e.DELETE),
+		CONNECT: wrap(e.CONNECT),
+		OPTIONS: wrap(e.OPTIONS),
+		TRACE:   wrap(e.TRACE),
+		PATCH:   wrap(e.PATCH),
+	}
+}
+
+// Wrap all endpoints of the router in the given middlewares.
+//
+// Can be used to apply middlewares to a group of routes
+// before merging it with other routes.
+func (r Router) With(mws ...Middleware) Router {
+	result := make(Router, len(r))
+	for path, endpoint := range r {
+		result[path] = endpoint.With(mws...)
+	}
+	return result
+}
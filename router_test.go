@@ -49,3 +49,32 @@ func TestRouter(t *testing.T) {
 	// subpaths
 	eq(must(http.Get(s.URL+"/post/1")).StatusCode, 404)
 }
+
+func TestRouter_With(t *testing.T) {
+	trace := ""
+	mw := func(name string) josh.Middleware {
+		return josh.Lift(func(h josh.Handler) josh.Handler {
+			return func(r josh.Req) josh.Resp {
+				trace += name
+				return h(r)
+			}
+		})
+	}
+	r := josh.Router{
+		"/post": {GET: josh.Wrap(noop)},
+		"/user": josh.Endpoint{GET: josh.Wrap(noop)}.With(mw("c")),
+	}.With(mw("a"), mw("b"))
+	eq(r["/post"].POST == nil, true)
+
+	req := httptest.NewRequest("GET", "/post", nil)
+	w := httptest.NewRecorder()
+	r["/post"].GET(w, req)
+	eq(w.Code, 204)
+	eq(trace, "ab")
+
+	trace = ""
+	w = httptest.NewRecorder()
+	r["/user"].GET(w, req)
+	eq(w.Code, 204)
+	eq(trace, "abc")
+}
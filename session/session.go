@@ -0,0 +1,404 @@
+// Package session provides cookie-based sessions for browser clients.
+//
+// The session data is either stored in a [Store] on the server side
+// (and the cookie contains only the session ID) or, if there is no store,
+// in the cookie itself. In both cases, the cookie is signed
+// and can optionally be encrypted.
+//
+//	type Data struct {
+//		UserID int
+//	}
+//
+//	m := josh.Must(session.New[Data](session.Config{
+//		SigningKey: signingKey,
+//		Store:      session.NewMemoryStore(),
+//	}))
+//	h = session.Handle(m, h)
+//
+// Cookie sessions are vulnerable to CSRF, so use them together with [middlewares.CSRF].
+//
+// [middlewares.CSRF]: https://pkg.go.dev/github.com/orsinium-labs/josh/middlewares#CSRF
+package session
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/orsinium-labs/josh"
+)
+
+// The maximum size of a cookie value that all browsers support.
+const maxCookieSize = 4096
+
+var (
+	ErrInvalidCookie = errors.New("invalid session cookie")
+	ErrExpired       = errors.New("session is expired")
+	ErrTooLarge      = errors.New("session data is too large for a cookie")
+)
+
+// Config of the session [Manager].
+type Config struct {
+	// The secret key for signing the cookie. Must be at least 32 bytes. Required.
+	SigningKey []byte
+
+	// The key for encrypting the cookie with AES-GCM. Must be 16, 24, or 32 bytes.
+	//
+	// If empty, the cookie is signed but not encrypted, so the client can read
+	// the session data (when there is no [Store]) but cannot modify it.
+	EncryptionKey []byte
+
+	// The server-side storage for the session data.
+	//
+	// If nil, the session data is stored in the cookie,
+	// and so it must fit into 4 KB after encoding.
+	Store Store
+
+	// The name of the cookie. Defaults to "session".
+	CookieName string
+
+	// The cookie path. Defaults to "/".
+	Path string
+
+	// The cookie domain. If empty, the cookie is sent only to the host that has set it.
+	Domain string
+
+	// How long the session lives since the last change. Defaults to 24 hours.
+	MaxAge time.Duration
+
+	// The SameSite attribute of the cookie. Defaults to [http.SameSiteLaxMode].
+	SameSite http.SameSite
+
+	// Allow sending the cookie over plain HTTP. Use it only for local development.
+	Insecure bool
+}
+
+// Manager loads and saves sessions with the data of type S.
+//
+// Must be constructed using [New].
+type Manager[S any] struct {
+	cfg  Config
+	aead cipher.AEAD
+}
+
+// Create a new session [Manager].
+func New[S any](cfg Config) (*Manager[S], error) {
+	if len(cfg.SigningKey) < 32 {
+		return nil, errors.New("signing key must be at least 32 bytes")
+	}
+	if cfg.CookieName == "" {
+		cfg.CookieName = "session"
+	}
+	if cfg.Path == "" {
+		cfg.Path = "/"
+	}
+	if cfg.MaxAge == 0 {
+		cfg.MaxAge = 24 * time.Hour
+	}
+	if cfg.SameSite == 0 {
+		cfg.SameSite = http.SameSiteLaxMode
+	}
+	m := &Manager[S]{cfg: cfg}
+	if len(cfg.EncryptionKey) != 0 {
+		block, err := aes.NewCipher(cfg.EncryptionKey)
+		if err != nil {
+			return nil, fmt.Errorf("encryption key: %w", err)
+		}
+		m.aead, err = cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("encryption key: %w", err)
+		}
+	}
+	return m, nil
+}
+
+// Session is the session of the current request.
+//
+// Use [Get] to get it from the request. Not safe for concurrent use.
+type Session[S any] struct {
+	id        string
+	oldID     string
+	data      S
+	isNew     bool
+	dirty     bool
+	destroyed bool
+}
+
+// The content of the cookie.
+type payload struct {
+	ID      string          `json:"id"`
+	Expires int64           `json:"exp"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+// Get the session of the request added by [Handle].
+func Get[S any](r josh.Req) (*Session[S], error) {
+	return josh.GetSingleton[*Session[S]](r)
+}
+
+// The session ID. Empty for a new session that hasn't been saved yet.
+func (s *Session[S]) ID() string {
+	return s.id
+}
+
+// True if the request had no valid session.
+func (s *Session[S]) IsNew() bool {
+	return s.isNew
+}
+
+// Get the session data.
+func (s *Session[S]) Get() S {
+	return s.data
+}
+
+// Replace the session data.
+//
+// The session will be saved after the handler finishes.
+func (s *Session[S]) Set(data S) {
+	s.data = data
+	s.dirty = true
+	s.destroyed = false
+}
+
+// Assign a new ID to the session, keeping the data.
+//
+// Call it when the privilege level changes (like on login or logout)
+// to protect against session fixation.
+func (s *Session[S]) Rotate() {
+	if s.oldID == "" {
+		s.oldID = s.id
+	}
+	s.id = ""
+	s.dirty = true
+}
+
+// Delete the session and its cookie.
+func (s *Session[S]) Destroy() {
+	var empty S
+	s.data = empty
+	s.destroyed = true
+	s.dirty = false
+}
+
+// Load the session before calling the handler and save it afterwards.
+//
+// A missing, tampered, or expired cookie results in a new empty session.
+// The cookie is set after the handler returns, so it won't be sent if the handler
+// writes the response directly into [http.ResponseWriter].
+// If the store fails, the error is logged using the [slog.Logger]
+// from the request context and 500 is returned.
+// The session can be accessed in the handler using [Get].
+func Handle[S any](m *Manager[S], h josh.Handler) josh.Handler {
+	return func(r josh.Req) josh.Resp {
+		sess, err := m.load(r)
+		if err != nil {
+			logError(r, "cannot load session", err)
+			return josh.InternalServerError(josh.Error{
+				Title:  "Cannot load session",
+				Detail: "The session storage is unavailable",
+			})
+		}
+		r = josh.Must(josh.WithSingleton(r, sess))
+		resp := h(r)
+		err = m.commit(r, sess)
+		if err != nil {
+			logError(r, "cannot save session", err)
+			return josh.InternalServerError(josh.Error{
+				Title:  "Cannot save session",
+				Detail: "The session storage is unavailable",
+			})
+		}
+		return resp
+	}
+}
+
+// Log the store error using the logger from the request context, if any.
+//
+// The error isn't sent to the client because it can reveal internal details.
+func logError(r josh.Req, msg string, err error) {
+	logger, _ := josh.GetSingleton[*slog.Logger](r)
+	if logger != nil {
+		logger.ErrorContext(r.Context(), msg, "error", err)
+	}
+}
+
+// Load the session from the request cookie.
+//
+// Returns an error only if the store fails.
+func (m *Manager[S]) load(r josh.Req) (*Session[S], error) {
+	sess := &Session[S]{isNew: true}
+	cookie, err := r.Cookie(m.cfg.CookieName)
+	if err != nil {
+		return sess, nil
+	}
+	p, err := m.decode(cookie.Value)
+	if err != nil {
+		return sess, nil
+	}
+	data := []byte(p.Data)
+	if m.cfg.Store != nil {
+		data, err = m.cfg.Store.Load(r.Context(), p.ID)
+		if errors.Is(err, ErrNotFound) {
+			return sess, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(data) != 0 {
+		err = json.Unmarshal(data, &sess.data)
+		if err != nil {
+			// The data type might have changed since the session was saved.
+			return sess, nil
+		}
+	}
+	sess.id = p.ID
+	sess.isNew = false
+	return sess, nil
+}
+
+// Save the changed session and set the cookie.
+func (m *Manager[S]) commit(r josh.Req, sess *Session[S]) error {
+	ctx := r.Context()
+	store := m.cfg.Store
+	if sess.destroyed {
+		for _, id := range []string{sess.id, sess.oldID} {
+			if store != nil && id != "" {
+				err := store.Delete(ctx, id)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		if !sess.isNew {
+			m.setCookie(r, "", -1)
+		}
+		return nil
+	}
+	if !sess.dirty {
+		return nil
+	}
+	if sess.id == "" {
+		sess.id = newID()
+	}
+	data, err := json.Marshal(sess.data)
+	if err != nil {
+		return err
+	}
+	p := payload{ID: sess.id, Expires: time.Now().Add(m.cfg.MaxAge).Unix()}
+	if store != nil {
+		err = store.Save(ctx, sess.id, data, m.cfg.MaxAge)
+		if err != nil {
+			return err
+		}
+		if sess.oldID != "" {
+			err = store.Delete(ctx, sess.oldID)
+			if err != nil {
+				return err
+			}
+		}
+	} else {
+		p.Data = data
+	}
+	value, err := m.encode(p)
+	if err != nil {
+		return err
+	}
+	m.setCookie(r, value, int(m.cfg.MaxAge.Seconds()))
+	sess.oldID = ""
+	sess.dirty = false
+	return nil
+}
+
+func (m *Manager[S]) setCookie(r josh.Req, value string, maxAge int) {
+	w := josh.Must(josh.GetSingleton[http.ResponseWriter](r))
+	http.SetCookie(w, &http.Cookie{
+		Name:     m.cfg.CookieName,
+		Value:    value,
+		Path:     m.cfg.Path,
+		Domain:   m.cfg.Domain,
+		MaxAge:   maxAge,
+		Secure:   !m.cfg.Insecure,
+		HttpOnly: true,
+		SameSite: m.cfg.SameSite,
+	})
+}
+
+// Serialize, encrypt (if enabled), and sign the cookie payload.
+func (m *Manager[S]) encode(p payload) (string, error) {
+	raw, err := json.Marshal(p)
+	if err != nil {
+		return "", err
+	}
+	if m.aead != nil {
+		nonce := make([]byte, m.aead.NonceSize(), m.aead.NonceSize()+len(raw)+m.aead.Overhead())
+		_, _ = rand.Read(nonce)
+		raw = m.aead.Seal(nonce, nonce, raw, []byte(m.cfg.CookieName))
+	}
+	body := base64.RawURLEncoding.EncodeToString(raw)
+	value := body + "." + base64.RawURLEncoding.EncodeToString(m.sign(body))
+	if len(value) > maxCookieSize {
+		return "", ErrTooLarge
+	}
+	return value, nil
+}
+
+// Verify, decrypt (if enabled), and parse the cookie payload.
+func (m *Manager[S]) decode(value string) (payload, error) {
+	var p payload
+	body, rawSig, found := strings.Cut(value, ".")
+	if !found {
+		return p, ErrInvalidCookie
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(rawSig)
+	if err != nil || !hmac.Equal(sig, m.sign(body)) {
+		return p, ErrInvalidCookie
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(body)
+	if err != nil {
+		return p, ErrInvalidCookie
+	}
+	if m.aead != nil {
+		size := m.aead.NonceSize()
+		if len(raw) < size {
+			return p, ErrInvalidCookie
+		}
+		raw, err = m.aead.Open(nil, raw[:size], raw[size:], []byte(m.cfg.CookieName))
+		if err != nil {
+			return p, ErrInvalidCookie
+		}
+	}
+	err = json.Unmarshal(raw, &p)
+	if err != nil || p.ID == "" {
+		return p, ErrInvalidCookie
+	}
+	if time.Now().Unix() >= p.Expires {
+		return p, ErrExpired
+	}
+	return p, nil
+}
+
+// Generate a random session ID.
+func newID() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// The signature of the encoded cookie body. The cookie name is signed too,
+// so that a value of one cookie cannot be used for another.
+func (m *Manager[S]) sign(body string) []byte {
+	mac := hmac.New(sha256.New, m.cfg.SigningKey)
+	mac.Write([]byte(m.cfg.CookieName + "=" + body))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,152 @@
+package session_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/orsinium-labs/josh"
+	"github.com/orsinium-labs/josh/session"
+)
+
+func eq[T comparable](a, b T) {
+	if a != b {
+		panic(fmt.Sprintf("%v != %v", a, b))
+	}
+}
+
+type Data struct {
+	User string `json:"user"`
+}
+
+// Handler that logs in, rotates, or logs out depending on the path.
+func handler(r josh.Req) josh.Resp {
+	sess := josh.Must(session.Get[Data](r))
+	switch r.URL.Path {
+	case "/login":
+		sess.Set(Data{User: "aragorn"})
+		sess.Rotate()
+	case "/logout":
+		sess.Destroy()
+	}
+	return josh.Ok(sess.Get().User)
+}
+
+// Send the request with the given cookie and return the response body and the new cookie.
+func send(h http.HandlerFunc, path string, cookie *http.Cookie) (string, *http.Cookie) {
+	req := httptest.NewRequest("GET", "http://example.com"+path, nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	h(w, req)
+	eq(w.Code, 200)
+	cookies := w.Result().Cookies()
+	if len(cookies) == 0 {
+		return strings.TrimSpace(w.Body.String()), nil
+	}
+	return strings.TrimSpace(w.Body.String()), cookies[0]
+}
+
+func TestSession_Store(t *testing.T) {
+	m := josh.Must(session.New[Data](session.Config{
+		SigningKey: []byte(strings.Repeat("k", 32)),
+		Store:      session.NewMemoryStore(),
+	}))
+	h := josh.Wrap(session.Handle(m, handler))
+
+	body, cookie := send(h, "/", nil)
+	eq(body, `{"data":""}`)
+	eq(cookie == nil, true)
+
+	body, cookie = send(h, "/login", nil)
+	eq(body, `{"data":"aragorn"}`)
+	eq(cookie.HttpOnly, true)
+	eq(cookie.Secure, true)
+	eq(strings.Contains(cookie.Value, "aragorn"), false)
+
+	body, same := send(h, "/", cookie)
+	eq(body, `{"data":"aragorn"}`)
+	eq(same == nil, true)
+
+	// Rotation invalidates the old session ID.
+	_, rotated := send(h, "/login", cookie)
+	eq(rotated.Value != cookie.Value, true)
+	body, _ = send(h, "/", cookie)
+	eq(body, `{"data":""}`)
+	body, _ = send(h, "/", rotated)
+	eq(body, `{"data":"aragorn"}`)
+
+	_, removed := send(h, "/logout", rotated)
+	eq(removed.MaxAge, -1)
+	body, _ = send(h, "/", rotated)
+	eq(body, `{"data":""}`)
+}
+
+func TestSession_Cookie(t *testing.T) {
+	m := josh.Must(session.New[Data](session.Config{
+		SigningKey:    []byte(strings.Repeat("k", 32)),
+		EncryptionKey: []byte(strings.Repeat("e", 32)),
+	}))
+	h := josh.Wrap(session.Handle(m, handler))
+
+	_, cookie := send(h, "/login", nil)
+	body, _ := send(h, "/", cookie)
+	eq(body, `{"data":"aragorn"}`)
+
+	// Tampered cookie.
+	value := []byte(cookie.Value)
+	value[5] ^= 1
+	body, _ = send(h, "/", &http.Cookie{Name: cookie.Name, Value: string(value)})
+	eq(body, `{"data":""}`)
+
+	// Signed with another key.
+	other := josh.Must(session.New[Data](session.Config{
+		SigningKey:    []byte(strings.Repeat("o", 32)),
+		EncryptionKey: []byte(strings.Repeat("e", 32)),
+	}))
+	_, forged := send(josh.Wrap(session.Handle(other, handler)), "/login", nil)
+	body, _ = send(h, "/", forged)
+	eq(body, `{"data":""}`)
+}
+
+// Store that always fails.
+type brokenStore struct{}
+
+func (brokenStore) Load(ctx context.Context, id string) ([]byte, error) {
+	return nil, errors.New("connection refused to 10.0.0.1")
+}
+
+func (brokenStore) Save(ctx context.Context, id string, data []byte, ttl time.Duration) error {
+	return errors.New("connection refused to 10.0.0.1")
+}
+
+func (brokenStore) Delete(ctx context.Context, id string) error {
+	return errors.New("connection refused to 10.0.0.1")
+}
+
+func TestSession_StoreError(t *testing.T) {
+	m := josh.Must(session.New[Data](session.Config{
+		SigningKey: []byte(strings.Repeat("k", 32)),
+		Store:      brokenStore{},
+	}))
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+	h := josh.Wrap(func(r josh.Req) josh.Resp {
+		r = josh.Must(josh.WithSingleton(r, logger))
+		return session.Handle(m, handler)(r)
+	})
+	req := httptest.NewRequest("GET", "http://example.com/login", nil)
+	w := httptest.NewRecorder()
+	h(w, req)
+	eq(w.Code, 500)
+	eq(strings.Contains(w.Body.String(), "10.0.0.1"), false)
+	eq(strings.Contains(logs.String(), "connection refused to 10.0.0.1"), true)
+}
@@ -0,0 +1,83 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrNotFound is returned by [Store.Load] if there is no session with the given ID.
+var ErrNotFound = errors.New("session not found")
+
+// Store keeps session data on the server side.
+//
+// The implementation must be safe for concurrent use.
+// See [NewMemoryStore] for an in-memory implementation.
+type Store interface {
+	// Get the session data. Returns [ErrNotFound] if the session doesn't exist or expired.
+	Load(ctx context.Context, id string) ([]byte, error)
+
+	// Create or replace the session data. The session should expire after the TTL.
+	Save(ctx context.Context, id string, data []byte, ttl time.Duration) error
+
+	// Delete the session. Must not fail if the session doesn't exist.
+	Delete(ctx context.Context, id string) error
+}
+
+type memoryEntry struct {
+	data    []byte
+	expires time.Time
+}
+
+// MemoryStore is an in-memory [Store].
+//
+// The sessions are lost when the service restarts and aren't shared
+// between instances of the service, so it's mostly useful for tests
+// and single-instance deployments.
+type MemoryStore struct {
+	mu        sync.Mutex
+	sessions  map[string]memoryEntry
+	lastSweep time.Time
+}
+
+// Create a new empty [MemoryStore].
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{sessions: make(map[string]memoryEntry)}
+}
+
+// Load implements [Store].
+func (s *MemoryStore) Load(ctx context.Context, id string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, found := s.sessions[id]
+	if !found || time.Now().After(entry.expires) {
+		return nil, ErrNotFound
+	}
+	return entry.data, nil
+}
+
+// Save implements [Store].
+func (s *MemoryStore) Save(ctx context.Context, id string, data []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if now.Sub(s.lastSweep) >= time.Minute {
+		for key, entry := range s.sessions {
+			if now.After(entry.expires) {
+				delete(s.sessions, key)
+			}
+		}
+		s.lastSweep = now
+	}
+	s.sessions[id] = memoryEntry{data: data, expires: now.Add(ttl)}
+	return nil
+}
+
+// Delete implements [Store].
+func (s *MemoryStore) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, id)
+	return nil
+}
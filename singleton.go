@@ -30,6 +30,15 @@ func CWithSingleton[T any](ctx context.Context, val T) (context.Context, error)
 	return ctx, nil
 }
 
+// Like [WithSingleton] but replaces the value of the same type if it's already in the context.
+//
+// Useful for middlewares that extend a value set by another middleware.
+// For example, to add more attributes to the logger.
+func ReplaceSingleton[T any](r Req, val T) Req {
+	ctx := context.WithValue(r.Context(), ctxKey[T]{}, val)
+	return r.WithContext(ctx)
+}
+
 // Get from the context the value added using [WithSingleton].
 //
 // If there is no value of the given type in the context, an error is returned.
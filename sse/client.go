@@ -0,0 +1,301 @@
+package sse
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/orsinium-labs/josh"
+	"github.com/orsinium-labs/josh/headers"
+)
+
+// The maximum length of a line in the event stream that [Reader] accepts.
+const maxLineSize = 1024 * 1024
+
+// Event is a server-sent event received by [Reader] or [Client].
+type Event struct {
+	// The event type. Empty for the default "message" type.
+	Event string
+
+	// The event data. Lines of multi-line data are joined with "\n".
+	Data string
+
+	// The last event ID. Unlike other fields, it's kept between events
+	// until the server sets a new one.
+	ID string
+}
+
+// Document is a JSON:API document in the event data.
+//
+// It's the client-side counterpart of [josh.Resp] with a typed Data.
+type Document[T any] struct {
+	Data     T               `json:"data"`
+	Errors   []josh.Error    `json:"errors"`
+	Included json.RawMessage `json:"included"`
+	JSONAPI  json.RawMessage `json:"jsonapi"`
+	Links    json.RawMessage `json:"links"`
+	Meta     json.RawMessage `json:"meta"`
+}
+
+// Decode the event data sent by [Stream.Send] into a [Document].
+func Decode[T any](e Event) (Document[T], error) {
+	var doc Document[T]
+	err := json.Unmarshal([]byte(e.Data), &doc)
+	return doc, err
+}
+
+// Reader parses the "text/event-stream" format.
+//
+// https://html.spec.whatwg.org/multipage/server-sent-events.html#event-stream-interpretation
+type Reader struct {
+	scanner *bufio.Scanner
+	lastID  string
+	retry   time.Duration
+	started bool
+}
+
+// Create a new [Reader] reading the event stream from the given reader.
+func NewReader(r io.Reader) *Reader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxLineSize)
+	scanner.Split(scanLines)
+	return &Reader{scanner: scanner}
+}
+
+// Read the next event.
+//
+// Comments and events without data are skipped.
+// Returns [io.EOF] when the stream ends. An incomplete last event is discarded.
+func (r *Reader) Next() (Event, error) {
+	var event Event
+	var data strings.Builder
+	hasData := false
+	for r.scanner.Scan() {
+		line := r.scanner.Text()
+		if !r.started {
+			line = strings.TrimPrefix(line, "\uFEFF")
+			r.started = true
+		}
+		if line == "" {
+			if !hasData {
+				event = Event{}
+				continue
+			}
+			event.Data = strings.TrimSuffix(data.String(), "\n")
+			event.ID = r.lastID
+			return event, nil
+		}
+		if line[0] == ':' {
+			continue
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			event.Event = value
+		case "data":
+			data.WriteString(value)
+			data.WriteByte('\n')
+			hasData = true
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				r.lastID = value
+			}
+		case "retry":
+			ms, err := strconv.ParseUint(value, 10, 32)
+			if err == nil {
+				r.retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+	err := r.scanner.Err()
+	if err == nil {
+		err = io.EOF
+	}
+	return Event{}, err
+}
+
+// The last reconnection time sent by the server. Zero if not sent.
+//
+// Unlike other fields, the server can send it without data.
+func (r *Reader) Retry() time.Duration {
+	return r.retry
+}
+
+// Split the stream into lines ending with "\r\n", "\n", or "\r".
+func scanLines(data []byte, atEOF bool) (int, []byte, error) {
+	i := bytes.IndexAny(data, "\r\n")
+	if i >= 0 {
+		if data[i] == '\n' {
+			return i + 1, data[:i], nil
+		}
+		// "\r" is the last byte read, "\n" might follow.
+		if i+1 == len(data) && !atEOF {
+			return 0, nil, nil
+		}
+		if i+1 < len(data) && data[i+1] == '\n' {
+			return i + 2, data[:i], nil
+		}
+		return i + 1, data[:i], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// Client consumes an event stream, reconnecting when the connection is lost.
+//
+// Must be constructed using [NewClient].
+type Client struct {
+	// The HTTP client to make requests. Defaults to [http.DefaultClient].
+	//
+	// Don't set a timeout for the client because it limits the stream duration.
+	HTTP *http.Client
+
+	// Additional request headers, like "Authorization".
+	Header http.Header
+
+	// The initial delay before reconnecting. Defaults to 3 seconds.
+	//
+	// The server can change it by sending the "retry" field.
+	Retry time.Duration
+
+	// The maximum delay between reconnection attempts. Defaults to 1 minute.
+	//
+	// The delay doubles after each failed attempt, up to this value.
+	MaxRetry time.Duration
+
+	// The ID of the last received event. Sent as the "Last-Event-ID" header
+	// on reconnect. Can be set before subscribing to resume a stream.
+	LastEventID string
+
+	url string
+}
+
+// Create a [Client] for the event stream at the given URL.
+func NewClient(url string) *Client {
+	return &Client{
+		Retry:    3 * time.Second,
+		MaxRetry: time.Minute,
+		url:      url,
+	}
+}
+
+// Receive events and call the handler for each of them until the context is canceled.
+//
+// If the connection is lost or the server responds with 5xx or 429,
+// the client reconnects after a delay. If the server responds with 204 No Content,
+// the client stops and returns nil. Any other status code is returned as an error.
+// If the handler returns an error, the client stops and returns it.
+func (c *Client) Subscribe(ctx context.Context, handler func(Event) error) error {
+	failures := 0
+	for {
+		result, err := c.connect(ctx, handler)
+		if err != nil {
+			return err
+		}
+		switch result {
+		case streamStopped:
+			return nil
+		case streamLost:
+			failures = 0
+		case streamFailed:
+			failures += 1
+		}
+		delay := c.Retry
+		for i := 1; i < failures && delay < c.MaxRetry; i++ {
+			delay *= 2
+		}
+		timer := time.NewTimer(min(delay, c.MaxRetry))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// The outcome of a single connection of [Client].
+type streamResult int
+
+const (
+	// The connection couldn't be established.
+	streamFailed streamResult = iota
+	// The connection was established and then lost.
+	streamLost
+	// The server asked to stop reconnecting.
+	streamStopped
+)
+
+// Connect to the server and handle events until the connection is lost.
+//
+// Returns an error if the client must not reconnect.
+func (c *Client) connect(ctx context.Context, handler func(Event) error) (streamResult, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		return streamFailed, err
+	}
+	for key, values := range c.Header {
+		req.Header[key] = values
+	}
+	req.Header.Set(string(headers.Accept), "text/event-stream")
+	req.Header.Set(string(headers.CacheControl), "no-cache")
+	if c.LastEventID != "" {
+		req.Header.Set(string(headers.LastEventID), c.LastEventID)
+	}
+	client := c.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return streamFailed, ctx.Err()
+		}
+		return streamFailed, nil
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNoContent:
+		return streamStopped, nil
+	case resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests:
+		return streamFailed, nil
+	case resp.StatusCode != http.StatusOK:
+		return streamFailed, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get(string(headers.ContentType)))
+	if mediaType != "text/event-stream" {
+		return streamFailed, fmt.Errorf("unexpected content type %q", mediaType)
+	}
+
+	reader := NewReader(resp.Body)
+	reader.lastID = c.LastEventID
+	for {
+		event, err := reader.Next()
+		// The server can send "id" and "retry" without data.
+		c.LastEventID = reader.lastID
+		if reader.Retry() != 0 {
+			c.Retry = reader.Retry()
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return streamLost, ctx.Err()
+			}
+			return streamLost, nil
+		}
+		err = handler(event)
+		if err != nil {
+			return streamLost, err
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package sse_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/orsinium-labs/josh"
+	"github.com/orsinium-labs/josh/sse"
+)
+
+func TestReader(t *testing.T) {
+	stream := "\uFEFF: comment\n" +
+		"event: greeting\r\n" +
+		"data: hello\n" +
+		"data:world\n" +
+		"id: 1\n\n" +
+		"retry: 500\n\n" +
+		"data: {\"data\": 42}\r\r" +
+		"data: incomplete"
+	r := sse.NewReader(strings.NewReader(stream))
+
+	e := josh.Must(r.Next())
+	eq(e.Event, "greeting")
+	eq(e.Data, "hello\nworld")
+	eq(e.ID, "1")
+
+	e = josh.Must(r.Next())
+	eq(e.Event, "")
+	eq(e.ID, "1")
+	eq(r.Retry(), 500*time.Millisecond)
+	doc := josh.Must(sse.Decode[int](e))
+	eq(doc.Data, 42)
+
+	_, err := r.Next()
+	eq(err, io.EOF)
+}
+
+func TestClient(t *testing.T) {
+	connections := 0
+	lastIDs := make(chan string, 3)
+	srv := httptest.NewServer(josh.Wrap(func(r josh.Req) josh.Resp {
+		connections += 1
+		lastIDs <- r.Header.Get("Last-Event-ID")
+		if connections == 3 {
+			return josh.NoContent()
+		}
+		stream := sse.New(r)
+		stream.Start()
+		defer stream.Close()
+		_ = stream.Send(sse.Message{
+			ID:    "id" + string(rune('0'+connections)),
+			Data:  josh.Ok(connections),
+			Retry: time.Millisecond,
+		})
+		return josh.NoResponse()
+	}))
+	defer srv.Close()
+
+	client := sse.NewClient(srv.URL)
+	var received []int
+	err := client.Subscribe(context.Background(), func(e sse.Event) error {
+		doc, err := sse.Decode[int](e)
+		received = append(received, doc.Data)
+		return err
+	})
+	eq(err, nil)
+	eq(len(received), 2)
+	eq(received[1], 2)
+	eq(<-lastIDs, "")
+	eq(<-lastIDs, "id1")
+	eq(<-lastIDs, "id2")
+	eq(client.Retry, time.Millisecond)
+
+	// Errors returned by the handler stop the client.
+	connections = 0
+	client = sse.NewClient(srv.URL)
+	err = client.Subscribe(context.Background(), func(e sse.Event) error {
+		return errors.New("oh no")
+	})
+	eq(err.Error(), "oh no")
+	<-lastIDs
+
+	// Client errors are not retried.
+	srv404 := httptest.NewServer(http.NotFoundHandler())
+	defer srv404.Close()
+	err = sse.NewClient(srv404.URL).Subscribe(context.Background(), func(e sse.Event) error {
+		return nil
+	})
+	eq(err.Error(), "unexpected status code 404")
+}
@@ -0,0 +1,100 @@
+package sse
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidField is returned by [Stream.Send] if the message Event or ID
+// contains characters that would break the stream framing.
+var ErrInvalidField = errors.New("event and ID must not contain line breaks or NUL")
+
+// Write the message in the "text/event-stream" format into the buffer.
+//
+// https://html.spec.whatwg.org/multipage/server-sent-events.html#parsing-an-event-stream
+func encode(buf *bytes.Buffer, msg Message) error {
+	// A line break would allow injecting arbitrary fields
+	// and NUL makes clients ignore the ID.
+	if strings.ContainsAny(msg.Event, "\r\n\x00") {
+		return fmt.Errorf("%w: event %q", ErrInvalidField, msg.Event)
+	}
+	if strings.ContainsAny(msg.ID, "\r\n\x00") {
+		return fmt.Errorf("%w: ID %q", ErrInvalidField, msg.ID)
+	}
+	data, hasData, err := payload(msg)
+	if err != nil {
+		return err
+	}
+
+	if msg.Event != "" {
+		buf.WriteString("event: ")
+		buf.WriteString(msg.Event)
+		buf.WriteByte('\n')
+	}
+	if hasData {
+		writeLines(buf, "data: ", data)
+	}
+	if msg.ID != "" {
+		buf.WriteString("id: ")
+		buf.WriteString(msg.ID)
+		buf.WriteByte('\n')
+	}
+	if msg.Retry > 0 {
+		buf.WriteString("retry: ")
+		buf.WriteString(strconv.FormatInt(msg.Retry.Milliseconds(), 10))
+		buf.WriteByte('\n')
+	}
+	if msg.Comment != "" {
+		writeLines(buf, ": ", msg.Comment)
+	}
+	buf.WriteByte('\n')
+	return nil
+}
+
+// Get the data of the message as text.
+//
+// Returns false if the message has no data.
+func payload(msg Message) (string, bool, error) {
+	resp := msg.Data
+	hasResp := resp.Data != nil || resp.Errors != nil || resp.Meta != nil || resp.Links != nil || resp.Included != nil
+	set := 0
+	for _, ok := range []bool{hasResp, msg.Text != "", msg.JSON != nil} {
+		if ok {
+			set += 1
+		}
+	}
+	if set > 1 {
+		return "", false, errors.New("only one of Data, Text, and JSON can be set")
+	}
+	switch {
+	case msg.Text != "":
+		return msg.Text, true, nil
+	case msg.JSON != nil:
+		if !json.Valid(msg.JSON) {
+			return "", false, errors.New("JSON is not valid")
+		}
+		return string(msg.JSON), true, nil
+	case hasResp:
+		raw, err := json.Marshal(resp)
+		if err != nil {
+			return "", false, err
+		}
+		return string(raw), true, nil
+	}
+	return "", false, nil
+}
+
+// Write the text with each line prefixed.
+func writeLines(buf *bytes.Buffer, prefix, text string) {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	for _, line := range strings.Split(text, "\n") {
+		buf.WriteString(prefix)
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+	}
+}
@@ -0,0 +1,64 @@
+package sse_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/orsinium-labs/josh"
+	"github.com/orsinium-labs/josh/sse"
+)
+
+func newStream() (sse.Stream, *httptest.ResponseRecorder) {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/", nil)
+	req = josh.Must(josh.WithSingleton[http.ResponseWriter](req, w))
+	stream := sse.New(req)
+	stream.Start()
+	return stream, w
+}
+
+func TestStream_Framing(t *testing.T) {
+	stream, w := newStream()
+	err := stream.SendBatch(
+		sse.Message{Text: "line 1\nline 2\r\nline 3", ID: "1"},
+		sse.Message{JSON: json.RawMessage("{\n\"data\": 1\n}"), Comment: "a\nb"},
+		sse.Message{Event: "ohno"},
+	)
+	eq(err, nil)
+	err = stream.SendErrorEvent("failure", josh.Error{Detail: "oh no"})
+	eq(err, nil)
+	stream.Close()
+	expected := "data: line 1\ndata: line 2\ndata: line 3\nid: 1\n\n" +
+		"data: {\ndata: \"data\": 1\ndata: }\n: a\n: b\n\n" +
+		"event: ohno\n\n" +
+		"event: failure\ndata: {\"errors\":[{\"detail\":\"oh no\"}]}\n\n"
+	eq(w.Body.String(), expected)
+
+	// Round trip through the reader.
+	r := sse.NewReader(strings.NewReader(expected))
+	eq(josh.Must(r.Next()).Data, "line 1\nline 2\nline 3")
+	doc := josh.Must(sse.Decode[int](josh.Must(r.Next())))
+	eq(doc.Data, 1)
+	e := josh.Must(r.Next())
+	eq(e.Event, "failure")
+	errDoc := josh.Must(sse.Decode[any](e))
+	eq(errDoc.Errors[0].Detail, "oh no")
+}
+
+func TestStream_InvalidFields(t *testing.T) {
+	stream, w := newStream()
+	err := stream.Send(sse.Message{Event: "a\ndata: injected", Text: "hi"})
+	eq(errors.Is(err, sse.ErrInvalidField), true)
+	err = stream.Send(sse.Message{ID: "1\r2"})
+	eq(errors.Is(err, sse.ErrInvalidField), true)
+	err = stream.SendBatch(sse.Message{Text: "ok"}, sse.Message{Text: "hi", Data: josh.Ok(1)})
+	eq(err != nil, true)
+	err = stream.Send(sse.Message{JSON: json.RawMessage("{")})
+	eq(err != nil, true)
+	stream.Close()
+	eq(w.Body.String(), "")
+}
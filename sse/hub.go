@@ -0,0 +1,224 @@
+package sse
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/orsinium-labs/josh"
+	"github.com/orsinium-labs/josh/headers"
+)
+
+// SlowConsumerPolicy defines what [Hub] does when a subscriber's buffer is full.
+type SlowConsumerPolicy int
+
+const (
+	// Drop the new messages for the subscriber until there is space in the buffer.
+	DropMessages SlowConsumerPolicy = iota
+
+	// Close the subscription. The client is expected to reconnect.
+	Disconnect
+)
+
+// Hub broadcasts messages to all clients subscribed to a topic.
+//
+// Must be constructed using [NewHub].
+type Hub struct {
+	// The store of recent messages for resuming streams. Optional.
+	//
+	// If set, the hub assigns an increasing numeric ID to each published message,
+	// and [Hub.Subscribe] resends the messages missed since the "Last-Event-ID"
+	// request header. If the missed messages aren't available anymore,
+	// the [ResyncEvent] event is sent first.
+	//
+	// Must be set before the hub is used. The store is called
+	// while the hub is locked, so it must not block.
+	Replay ReplayStore
+
+	buffer int
+	policy SlowConsumerPolicy
+	mu     sync.Mutex
+	topics map[string]map[*Subscription]struct{}
+	lastID uint64
+}
+
+// Create a new [Hub].
+//
+// The buffer is how many messages can be queued for each subscriber.
+// The policy defines what happens when the buffer is full.
+func NewHub(buffer int, policy SlowConsumerPolicy) *Hub {
+	return &Hub{
+		buffer: buffer,
+		policy: policy,
+		topics: make(map[string]map[*Subscription]struct{}),
+		// Start IDs from the current time so that they keep increasing
+		// after the service restarts.
+		lastID: uint64(time.Now().UnixMicro()),
+	}
+}
+
+// Subscription is a subscription of one client to one or more topics of [Hub].
+type Subscription struct {
+	hub     *Hub
+	topics  []string
+	ch      chan Message
+	closed  bool
+	dropped atomic.Int64
+}
+
+// Subscribe the client to the given topics.
+//
+// The subscription is closed when the request is finished or canceled
+// (see [josh.Canceled]), so you don't need to close it explicitly.
+//
+// If [Hub.Replay] is set and the request has the "Last-Event-ID" header,
+// the missed messages are queued before any new ones.
+func (h *Hub) Subscribe(r josh.Req, topics ...string) *Subscription {
+	sub := &Subscription{hub: h, topics: topics}
+	h.mu.Lock()
+	missed := h.missed(r, topics)
+	sub.ch = make(chan Message, h.buffer+len(missed))
+	for _, msg := range missed {
+		sub.ch <- msg
+	}
+	for _, topic := range topics {
+		subs := h.topics[topic]
+		if subs == nil {
+			subs = make(map[*Subscription]struct{})
+			h.topics[topic] = subs
+		}
+		subs[sub] = struct{}{}
+	}
+	h.mu.Unlock()
+	context.AfterFunc(r.Context(), sub.Close)
+	return sub
+}
+
+// Send the message to all subscribers of the topic.
+//
+// Never blocks, as long as [ReplayStore.Append] of the [Hub.Replay] doesn't.
+// Returns the number of subscribers that received the message.
+func (h *Hub) Publish(topic string, msg Message) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.Replay != nil {
+		h.lastID += 1
+		msg.ID = strconv.FormatUint(h.lastID, 10)
+		h.Replay.Append(topic, h.lastID, msg)
+	}
+	delivered := 0
+	for sub := range h.topics[topic] {
+		select {
+		case sub.ch <- msg:
+			delivered += 1
+		default:
+			sub.dropped.Add(1)
+			if h.policy == Disconnect {
+				h.unsubscribe(sub)
+			}
+		}
+	}
+	return delivered
+}
+
+// Get the messages missed by the reconnecting client.
+//
+// Must be called with the lock held.
+func (h *Hub) missed(r josh.Req, topics []string) []Message {
+	if h.Replay == nil {
+		return nil
+	}
+	header := r.Header.Get(string(headers.LastEventID))
+	if header == "" {
+		return nil
+	}
+	resync := []Message{{
+		Event: ResyncEvent,
+		ID:    strconv.FormatUint(h.lastID, 10),
+		// Events without data are not dispatched by clients.
+		Data: josh.Resp{Meta: map[string]bool{"resync": true}},
+	}}
+	lastID, err := strconv.ParseUint(header, 10, 64)
+	if err != nil {
+		return resync
+	}
+	var missed []Message
+	for _, topic := range topics {
+		msgs, ok := h.Replay.Since(topic, lastID)
+		if !ok {
+			return resync
+		}
+		missed = append(missed, msgs...)
+	}
+	// Restore the publishing order of messages from different topics.
+	slices.SortStableFunc(missed, func(a, b Message) int {
+		aID, _ := strconv.ParseUint(a.ID, 10, 64)
+		bID, _ := strconv.ParseUint(b.ID, 10, 64)
+		return cmp.Compare(aID, bID)
+	})
+	return missed
+}
+
+// The number of subscribers of the topic.
+func (h *Hub) Subscribers(topic string) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.topics[topic])
+}
+
+// Remove the subscription from all topics and close its channel.
+//
+// Must be called with the lock held.
+func (h *Hub) unsubscribe(sub *Subscription) {
+	if sub.closed {
+		return
+	}
+	sub.closed = true
+	for _, topic := range sub.topics {
+		subs := h.topics[topic]
+		delete(subs, sub)
+		if len(subs) == 0 {
+			delete(h.topics, topic)
+		}
+	}
+	close(sub.ch)
+}
+
+// Handler that subscribes the client to the topics and streams the messages.
+//
+// The topics function returns the topics for the request,
+// for example, based on path parameters or the authenticated user.
+// The stream ends when the client disconnects, the subscription is closed
+// by the [Disconnect] policy, or the stream is done (see [Stream.Done]).
+func (h *Hub) Handler(topics func(josh.Req) []string) josh.Handler {
+	return func(r josh.Req) josh.Resp {
+		stream := New(r)
+		sub := h.Subscribe(r, topics(r)...)
+		defer sub.Close()
+		_ = stream.Run(r.Context(), sub.Messages())
+		return josh.NoResponse()
+	}
+}
+
+// The channel with the published messages.
+//
+// The channel is closed when the subscription is closed.
+func (s *Subscription) Messages() <-chan Message {
+	return s.ch
+}
+
+// The number of messages that didn't fit into the buffer.
+func (s *Subscription) Dropped() int64 {
+	return s.dropped.Load()
+}
+
+// Unsubscribe from all topics. Safe to call multiple times.
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.unsubscribe(s)
+}
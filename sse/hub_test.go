@@ -0,0 +1,130 @@
+package sse_test
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/orsinium-labs/josh"
+	"github.com/orsinium-labs/josh/sse"
+)
+
+func eq[T comparable](a, b T) {
+	if a != b {
+		panic(fmt.Sprintf("%v != %v", a, b))
+	}
+}
+
+func TestHub_Subscribe(t *testing.T) {
+	hub := sse.NewHub(2, sse.DropMessages)
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest("GET", "/", nil).WithContext(ctx)
+	sub := hub.Subscribe(req, "orcs", "elves")
+	eq(hub.Subscribers("orcs"), 1)
+	eq(hub.Subscribers("dwarves"), 0)
+
+	eq(hub.Publish("orcs", sse.Message{ID: "1"}), 1)
+	eq(hub.Publish("elves", sse.Message{ID: "2"}), 1)
+	eq(hub.Publish("orcs", sse.Message{ID: "3"}), 0)
+	eq(hub.Publish("dwarves", sse.Message{ID: "4"}), 0)
+	eq(sub.Dropped(), int64(1))
+	eq((<-sub.Messages()).ID, "1")
+	eq((<-sub.Messages()).ID, "2")
+
+	// The client went away.
+	cancel()
+	_, ok := <-sub.Messages()
+	eq(ok, false)
+	eq(hub.Subscribers("orcs"), 0)
+	sub.Close()
+}
+
+func TestHub_Disconnect(t *testing.T) {
+	hub := sse.NewHub(1, sse.Disconnect)
+	req := httptest.NewRequest("GET", "/", nil)
+	slow := hub.Subscribe(req, "orcs")
+	eq(hub.Publish("orcs", sse.Message{ID: "1"}), 1)
+	eq(hub.Publish("orcs", sse.Message{ID: "2"}), 0)
+	eq(hub.Subscribers("orcs"), 0)
+	eq((<-slow.Messages()).ID, "1")
+	_, ok := <-slow.Messages()
+	eq(ok, false)
+}
+
+func TestHub_Handler(t *testing.T) {
+	hub := sse.NewHub(8, sse.DropMessages)
+	h := josh.Wrap(hub.Handler(func(r josh.Req) []string {
+		return []string{r.URL.Query().Get("topic")}
+	}))
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	resp := josh.Must(http.Get(srv.URL + "/?topic=orcs"))
+	defer resp.Body.Close()
+	eq(resp.Header.Get("Content-Type"), "text/event-stream")
+	for hub.Subscribers("orcs") == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	hub.Publish("orcs", sse.Message{Event: "attack", ID: "1"})
+	reader := bufio.NewReader(resp.Body)
+	eq(josh.Must(reader.ReadString('\n')), "event: attack\n")
+	eq(josh.Must(reader.ReadString('\n')), "id: 1\n")
+	eq(josh.Must(reader.ReadString('\n')), "\n")
+}
+
+func TestHub_Replay(t *testing.T) {
+	hub := sse.NewHub(8, sse.DropMessages)
+	hub.Replay = sse.NewRingBuffer(2, time.Minute)
+	subscribe := func(lastID string) []sse.Message {
+		req := httptest.NewRequest("GET", "/", nil)
+		if lastID != "" {
+			req.Header.Set("Last-Event-ID", lastID)
+		}
+		sub := hub.Subscribe(req, "orcs", "elves")
+		defer sub.Close()
+		var msgs []sse.Message
+		for len(sub.Messages()) > 0 {
+			msgs = append(msgs, <-sub.Messages())
+		}
+		return msgs
+	}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	live := hub.Subscribe(req, "orcs")
+	hub.Publish("orcs", sse.Message{Event: "o1"})
+	first := (<-live.Messages()).ID
+	hub.Publish("elves", sse.Message{Event: "e1"})
+	hub.Publish("orcs", sse.Message{Event: "o2"})
+	hub.Publish("orcs", sse.Message{Event: "o3"})
+
+	eq(len(subscribe("")), 0)
+
+	// Resume after the first message: "o1" is evicted but not needed.
+	msgs := subscribe(first)
+	eq(len(msgs), 3)
+	eq(msgs[0].Event, "e1")
+	eq(msgs[1].Event, "o2")
+	eq(msgs[2].Event, "o3")
+
+	// Resume from before the first message: "o1" is evicted.
+	msgs = subscribe("1")
+	eq(len(msgs), 1)
+	eq(msgs[0].Event, sse.ResyncEvent)
+	msgs = subscribe("ohno")
+	eq(msgs[0].Event, sse.ResyncEvent)
+
+	// Up to date.
+	<-live.Messages()
+	last := (<-live.Messages()).ID
+	eq(len(subscribe(last)), 0)
+}
+
+func TestNewRingBuffer_Invalid(t *testing.T) {
+	defer func() {
+		eq(recover() != nil, true)
+	}()
+	sse.NewRingBuffer(0, time.Minute)
+}
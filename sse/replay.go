@@ -0,0 +1,138 @@
+package sse
+
+import (
+	"sync"
+	"time"
+)
+
+// The event sent by [Hub] when the client reconnects with a Last-Event-ID
+// for which the missed messages are no longer available.
+//
+// The event data is a document with {"resync": true} meta.
+// The client should fetch the current state from scratch.
+const ResyncEvent = "resync"
+
+// ReplayStore keeps recent messages of each topic of [Hub]
+// to resend them to reconnecting clients.
+//
+// The IDs are assigned by [Hub] and are increasing across all topics.
+// The implementation must be safe for concurrent use.
+// See [NewRingBuffer] for an in-memory implementation.
+//
+// The methods are called while the hub is locked, so that a reconnecting client
+// gets each message exactly once, either from the store or live.
+// Because of that, they must be fast and must not block: a slow store stalls
+// all [Hub.Publish] and [Hub.Subscribe] calls. If the messages need to be
+// persisted over the network, do that in the background.
+type ReplayStore interface {
+	// Remember the message published to the topic.
+	Append(topic string, id uint64, msg Message)
+
+	// Get the messages published to the topic after the message with the given ID,
+	// in the order of publishing.
+	//
+	// Return false if some of the messages are no longer available
+	// or if the ID is unknown.
+	Since(topic string, id uint64) ([]Message, bool)
+}
+
+type replayEntry struct {
+	id  uint64
+	at  time.Time
+	msg Message
+}
+
+type replayRing struct {
+	entries []replayEntry
+	// The highest ID of the removed messages.
+	evicted uint64
+}
+
+// Remove the messages older than the given time.
+func (r *replayRing) expire(cutoff time.Time) {
+	n := 0
+	for n < len(r.entries) && r.entries[n].at.Before(cutoff) {
+		r.evicted = r.entries[n].id
+		n += 1
+	}
+	r.entries = r.entries[n:]
+}
+
+// RingBuffer is an in-memory [ReplayStore] keeping the latest messages of each topic.
+//
+// Must be constructed using [NewRingBuffer].
+type RingBuffer struct {
+	size   int
+	maxAge time.Duration
+	mu     sync.Mutex
+	topics map[string]*replayRing
+	first  uint64
+	last   uint64
+}
+
+// Create a [RingBuffer] keeping at most size messages for each topic.
+//
+// If maxAge is not zero, the messages older than that are removed too.
+// Panics if size is not positive.
+func NewRingBuffer(size int, maxAge time.Duration) *RingBuffer {
+	if size <= 0 {
+		panic("sse: non-positive size for NewRingBuffer")
+	}
+	return &RingBuffer{
+		size:   size,
+		maxAge: maxAge,
+		topics: make(map[string]*replayRing),
+	}
+}
+
+// Append implements [ReplayStore].
+func (b *RingBuffer) Append(topic string, id uint64, msg Message) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.first == 0 {
+		b.first = id
+	}
+	b.last = id
+	ring := b.topics[topic]
+	if ring == nil {
+		ring = &replayRing{}
+		b.topics[topic] = ring
+	}
+	now := time.Now()
+	if b.maxAge != 0 {
+		ring.expire(now.Add(-b.maxAge))
+	}
+	if len(ring.entries) >= b.size {
+		ring.evicted = ring.entries[0].id
+		ring.entries = ring.entries[1:]
+	}
+	ring.entries = append(ring.entries, replayEntry{id: id, at: now, msg: msg})
+}
+
+// Since implements [ReplayStore].
+func (b *RingBuffer) Since(topic string, id uint64) ([]Message, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	// The ID is from before the buffer was created (like before a restart)
+	// or from the future.
+	if b.first == 0 || id < b.first || id > b.last {
+		return nil, false
+	}
+	ring := b.topics[topic]
+	if ring == nil {
+		return nil, true
+	}
+	if b.maxAge != 0 {
+		ring.expire(time.Now().Add(-b.maxAge))
+	}
+	if ring.evicted > id {
+		return nil, false
+	}
+	var msgs []Message
+	for _, entry := range ring.entries {
+		if entry.id > id {
+			msgs = append(msgs, entry.msg)
+		}
+	}
+	return msgs, true
+}
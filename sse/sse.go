@@ -1,20 +1,87 @@
 package sse
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/orsinium-labs/josh"
 )
 
+// ErrShuttingDown is returned by [Stream.Send] when the server is shutting down.
+//
+// See [josh.ShuttingDown].
+var ErrShuttingDown = errors.New("the server is shutting down")
+
+// The number of started streams which requests are not finished yet.
+var openStreams atomic.Int64
+
+// Get the number of currently open streams in the process.
+func OpenStreams() int64 {
+	return openStreams.Load()
+}
+
+// ErrClosed is returned by [Stream.Send] when the stream is closed.
+//
+// See [Stream.Done].
+var ErrClosed = errors.New("the stream is closed")
+
+// Stream sends server-sent events to the client.
+//
+// Must be constructed using [New]. Use [Stream.Run] to send messages from a channel
+// or [Stream.Start], [Stream.Send], and [Stream.Close] to manage the stream manually.
 type Stream struct {
+	// How often to send a comment to keep the idle connection alive
+	// through proxies. Defaults to 15 seconds. Negative disables heartbeats.
+	//
+	// Heartbeats are sent from a separate goroutine, which is stopped
+	// by [Stream.Close] or, at the latest, when [josh.Wrap] finishes the response.
+	Heartbeat time.Duration
+
+	// How long each write can take. Defaults to 10 seconds. Zero means no limit.
+	//
+	// The write deadline of the connection is set only for the duration
+	// of each write and removed after it, so neither an idle stream
+	// nor [http.Server.WriteTimeout] kills the stream.
+	WriteTimeout time.Duration
+
+	// For how long the stream can stay open. Zero means forever.
+	//
+	// Periodically reconnecting clients are spread evenly between
+	// the instances of the service behind a load balancer.
+	MaxLifetime time.Duration
+
+	// The reconnection time sent to the client when MaxLifetime is reached.
+	Retry time.Duration
+
 	req     josh.Req
 	flusher http.Flusher
 	writer  http.ResponseWriter
 	started bool
+	state   *streamState
+}
+
+// The state shared with the goroutine sending heartbeats.
+type streamState struct {
+	mu       sync.Mutex
+	closed   bool
+	done     chan struct{}
+	doneOnce sync.Once
+	stop     chan struct{}
+	stopOnce sync.Once
+	finished chan struct{}
+}
+
+// Mark the stream as closed. Must be called with the lock held.
+func (st *streamState) close() {
+	st.closed = true
+	st.doneOnce.Do(func() { close(st.done) })
 }
 
 // Create a new SSE helper from a request.
@@ -25,19 +92,158 @@ func New(req josh.Req) Stream {
 		panic("streaming is disabled on the server")
 	}
 	return Stream{
-		req:     req,
-		flusher: flusher,
-		writer:  writer,
+		Heartbeat:    15 * time.Second,
+		WriteTimeout: 10 * time.Second,
+		req:          req,
+		flusher:      flusher,
+		writer:       writer,
+		state: &streamState{
+			done:     make(chan struct{}),
+			stop:     make(chan struct{}),
+			finished: make(chan struct{}),
+		},
 	}
 }
 
+// Send the response headers and start sending heartbeats.
+//
+// Call [Stream.Close] when you're done. If the handler returns without that,
+// the stream is closed when [josh.Wrap] finishes the response.
 func (s *Stream) Start() {
+	if s.started {
+		return
+	}
 	s.writer.Header().Set("Content-Type", "text/event-stream")
 	s.writer.Header().Set("Cache-Control", "no-cache")
 	s.writer.Header().Set("Connection", "keep-alive")
+	s.setWriteDeadline(s.WriteTimeout)
 	s.writer.WriteHeader(http.StatusOK)
 	s.flusher.Flush()
+	s.setWriteDeadline(0)
 	s.started = true
+	openStreams.Add(1)
+	context.AfterFunc(s.req.Context(), func() {
+		openStreams.Add(-1)
+	})
+	go s.manage()
+	// Make sure nothing is written after the handler returns.
+	// The error means the request doesn't come from [josh.Wrap].
+	_, _ = josh.Intercept(s.req, s.writer, s.Close)
+}
+
+// Closed when the client disconnects, the server is shutting down,
+// [Stream.MaxLifetime] is reached, or [Stream.Close] is called.
+//
+// After that, [Stream.Send] fails and the handler should return.
+func (s *Stream) Done() <-chan struct{} {
+	return s.state.done
+}
+
+// Stop sending heartbeats and close the stream.
+//
+// Safe to call multiple times. Called automatically when [josh.Wrap]
+// finishes the response, because the response cannot be written after that.
+func (s *Stream) Close() {
+	st := s.state
+	st.stopOnce.Do(func() { close(st.stop) })
+	if s.started {
+		<-st.finished
+	}
+	st.mu.Lock()
+	st.close()
+	st.mu.Unlock()
+}
+
+// Start the stream and send all messages from the channel until it's closed.
+//
+// Returns nil when the channel is closed, the context is canceled,
+// or the stream is done (see [Stream.Done]). The stream is closed on return.
+func (s *Stream) Run(ctx context.Context, msgs <-chan Message) error {
+	s.Start()
+	defer s.Close()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-s.Done():
+			return nil
+		case msg, ok := <-msgs:
+			if !ok {
+				return nil
+			}
+			err := s.Send(msg)
+			if errors.Is(err, ErrClosed) || errors.Is(err, ErrShuttingDown) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Send heartbeats and watch for the stream end.
+func (s *Stream) manage() {
+	st := s.state
+	defer close(st.finished)
+	var heartbeat <-chan time.Time
+	if s.Heartbeat > 0 {
+		ticker := time.NewTicker(s.Heartbeat)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+	}
+	var lifetime <-chan time.Time
+	if s.MaxLifetime > 0 {
+		timer := time.NewTimer(s.MaxLifetime)
+		defer timer.Stop()
+		lifetime = timer.C
+	}
+	for {
+		select {
+		case <-st.stop:
+			return
+		case <-s.req.Context().Done():
+		case <-josh.ShuttingDown(s.req):
+		case <-lifetime:
+			st.mu.Lock()
+			if !st.closed && s.Retry > 0 {
+				_ = s.write([]byte("retry: " + strconv.FormatInt(s.Retry.Milliseconds(), 10) + "\n\n"))
+			}
+			st.close()
+			st.mu.Unlock()
+			return
+		case <-heartbeat:
+			st.mu.Lock()
+			if !st.closed {
+				_ = s.write([]byte(":\n\n"))
+			}
+			st.mu.Unlock()
+			continue
+		}
+		st.mu.Lock()
+		st.close()
+		st.mu.Unlock()
+		return
+	}
+}
+
+// Write and flush the data within the write timeout. Must be called with the lock held.
+func (s *Stream) write(data []byte) error {
+	s.setWriteDeadline(s.WriteTimeout)
+	defer s.setWriteDeadline(0)
+	_, err := s.writer.Write(data)
+	s.flusher.Flush()
+	return err
+}
+
+// Set the write deadline to the given time from now. Zero removes the deadline.
+func (s *Stream) setWriteDeadline(timeout time.Duration) {
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	// The error is ErrNotSupported if the writer cannot set deadlines.
+	_ = http.NewResponseController(s.writer).SetWriteDeadline(deadline)
 }
 
 // Send a success message to the client. Typically, a [josh.Data] instance.
@@ -51,60 +257,56 @@ func (s *Stream) SendOk(data any) error {
 }
 
 // Send an error message to the client.
+//
+// Use [Stream.SendErrorEvent] to send errors as a named event.
 func (s *Stream) SendError(data josh.Error) error {
 	msg := Message{Data: josh.BadRequest(data)}
 	return s.Send(msg)
 }
 
+// Send errors to the client as an event with the given name.
+//
+// The client can listen for the named event separately from regular messages.
+func (s *Stream) SendErrorEvent(event string, errs ...josh.Error) error {
+	msg := Message{Event: event, Data: josh.Resp{Errors: errs}}
+	return s.Send(msg)
+}
+
 // Send a message with additional attributes.
 //
 // Use [SendOk] or [SendError] if the only field you use is Data.
 func (s *Stream) Send(msg Message) error {
+	return s.SendBatch(msg)
+}
+
+// Send multiple messages at once.
+//
+// The messages are written and flushed together, which is faster
+// than sending them one by one. If any message is invalid, nothing is sent.
+func (s *Stream) SendBatch(msgs ...Message) error {
 	if !s.started {
 		return errors.New("you must Start SSE connection before you can Send")
 	}
-	var resErr error
-	w := func(data []byte) {
-		_, writeErr := s.writer.Write(data)
-		if resErr == nil && writeErr != nil {
-			resErr = writeErr
-		}
-	}
-
-	if msg.Event != "" {
-		w([]byte("event: "))
-		w([]byte(msg.Event))
-		w([]byte{'\n'})
+	select {
+	case <-josh.ShuttingDown(s.req):
+		return ErrShuttingDown
+	default:
 	}
-	if msg.Data.Data != nil || len(msg.Data.Errors) != 0 {
-		w([]byte("data: "))
-		raw, err := json.Marshal(msg.Data)
+	var buf bytes.Buffer
+	for _, msg := range msgs {
+		err := encode(&buf, msg)
 		if err != nil {
 			return err
 		}
-		w(raw)
-		w([]byte{'\n'})
-	}
-	if msg.ID != "" {
-		w([]byte("id: "))
-		w([]byte(msg.ID))
-		w([]byte{'\n'})
-	}
-	if msg.Retry != 0 {
-		w([]byte("retry: "))
-		reconn := strconv.FormatInt(msg.Retry.Milliseconds(), 10)
-		w([]byte(reconn))
-		w([]byte{'\n'})
-	}
-	if msg.Comment != "" {
-		w([]byte{':', ' '})
-		w([]byte(msg.Comment))
-		w([]byte{'\n'})
 	}
 
-	w([]byte{'\n'})
-	s.flusher.Flush()
-	return resErr
+	st := s.state
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.closed {
+		return ErrClosed
+	}
+	return s.write(buf.Bytes())
 }
 
 type Message struct {
@@ -119,6 +321,17 @@ type Message struct {
 	// The data field for the message. Will be JSON-encoded.
 	Data josh.Resp
 
+	// Plain text data for the message, sent instead of Data.
+	//
+	// Multi-line text is sent as multiple "data" lines
+	// and the client receives it with "\n" line endings.
+	Text string
+
+	// Already JSON-encoded data for the message, sent instead of Data.
+	//
+	// Useful for sending the same payload to many clients without re-encoding.
+	JSON json.RawMessage
+
 	// The event ID to set the EventSource object's last event ID value.
 	ID string
 
@@ -128,5 +341,7 @@ type Message struct {
 
 	// The comment line can be used to prevent connections from timing out;
 	// a server can send a comment periodically to keep the connection alive.
+	//
+	// Multi-line comments are sent as multiple comment lines.
 	Comment string
 }
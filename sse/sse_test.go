@@ -0,0 +1,103 @@
+package sse_test
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/orsinium-labs/josh"
+	"github.com/orsinium-labs/josh/sse"
+)
+
+func TestStream_Lifetime(t *testing.T) {
+	msgs := make(chan sse.Message)
+	done := make(chan error, 1)
+	h := josh.Wrap(func(r josh.Req) josh.Resp {
+		stream := sse.New(r)
+		stream.Heartbeat = 10 * time.Millisecond
+		stream.MaxLifetime = 100 * time.Millisecond
+		stream.Retry = 2 * time.Second
+		done <- stream.Run(r.Context(), msgs)
+		eq(stream.Send(sse.Message{Comment: "late"}), sse.ErrClosed)
+		return josh.NoResponse()
+	})
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	resp := josh.Must(http.Get(srv.URL))
+	defer resp.Body.Close()
+	reader := bufio.NewReader(resp.Body)
+
+	msgs <- sse.Message{Event: "hello"}
+	eq(josh.Must(reader.ReadString('\n')), "event: hello\n")
+	eq(josh.Must(reader.ReadString('\n')), "\n")
+
+	heartbeats := 0
+	for {
+		line := josh.Must(reader.ReadString('\n'))
+		if line == ":\n" {
+			heartbeats += 1
+			continue
+		}
+		if line == "retry: 2000\n" {
+			break
+		}
+		eq(line, "\n")
+	}
+	eq(heartbeats > 0, true)
+	eq(<-done, nil)
+}
+
+func TestStream_Close(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest("GET", "/", nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+	req = josh.Must(josh.WithSingleton[http.ResponseWriter](req, w))
+	stream := sse.New(req)
+	stream.Start()
+	eq(stream.Send(sse.Message{ID: "1"}), nil)
+	cancel()
+	<-stream.Done()
+	eq(stream.Send(sse.Message{ID: "2"}), sse.ErrClosed)
+	stream.Close()
+	eq(w.Body.String(), "id: 1\n\n")
+}
+
+func TestStream_Idle(t *testing.T) {
+	h := josh.Wrap(func(r josh.Req) josh.Resp {
+		stream := sse.New(r)
+		stream.WriteTimeout = 20 * time.Millisecond
+		stream.Start()
+		// Idle for longer than both the stream and the server write timeouts.
+		time.Sleep(100 * time.Millisecond)
+		eq(stream.Send(sse.Message{Event: "hello"}), nil)
+		return josh.NoResponse()
+	})
+	srv := httptest.NewUnstartedServer(h)
+	srv.Config.WriteTimeout = 50 * time.Millisecond
+	srv.Start()
+	defer srv.Close()
+	resp := josh.Must(http.Get(srv.URL))
+	defer resp.Body.Close()
+	reader := bufio.NewReader(resp.Body)
+	eq(josh.Must(reader.ReadString('\n')), "event: hello\n")
+}
+
+func TestStream_NotClosed(t *testing.T) {
+	var stream sse.Stream
+	h := josh.Wrap(func(r josh.Req) josh.Resp {
+		stream = sse.New(r)
+		eq(stream.Heartbeat, 15*time.Second)
+		stream.Start()
+		return josh.NoResponse()
+	})
+	h(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	// The heartbeats are stopped when the handler returns.
+	select {
+	case <-stream.Done():
+	default:
+		t.Fatal("the stream is not closed")
+	}
+}
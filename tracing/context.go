@@ -0,0 +1,130 @@
+package tracing
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+// The maximum length of the "tracestate" header that is propagated.
+//
+// https://www.w3.org/TR/trace-context/#tracestate-limits
+const maxTraceState = 512
+
+// TraceID is a unique identifier of a distributed trace.
+type TraceID [16]byte
+
+// Hex-encoded trace ID.
+func (id TraceID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// Check if the trace ID is not all zeros.
+func (id TraceID) IsValid() bool {
+	return id != TraceID{}
+}
+
+// SpanID is a unique identifier of a span within a trace.
+type SpanID [8]byte
+
+// Hex-encoded span ID.
+func (id SpanID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// Check if the span ID is not all zeros.
+func (id SpanID) IsValid() bool {
+	return id != SpanID{}
+}
+
+// SpanContext is the part of a span propagated across services.
+//
+// https://www.w3.org/TR/trace-context/
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+
+	// Trace flags. The only defined flag is "sampled".
+	Flags byte
+
+	// The raw value of the "tracestate" header.
+	State string
+}
+
+// Check if the trace is sampled, that is, the caller may have recorded it.
+func (sc SpanContext) Sampled() bool {
+	return sc.Flags&0x01 != 0
+}
+
+// Check if the trace ID and the span ID are valid.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// Format the span context as a "traceparent" header value.
+func (sc SpanContext) TraceParent() string {
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + hex.EncodeToString([]byte{sc.Flags})
+}
+
+// Parse the value of a "traceparent" header.
+//
+// The returned span context has the span ID of the caller.
+func ParseTraceParent(s string) (SpanContext, error) {
+	var sc SpanContext
+	s = strings.TrimSpace(s)
+	if len(s) < 55 {
+		return sc, errors.New("traceparent is too short")
+	}
+	version := s[:2]
+	if version == "ff" || !isLowerHex(version) {
+		return sc, errors.New("invalid traceparent version")
+	}
+	// Future versions may append more fields after a dash.
+	if (version == "00" && len(s) != 55) || (len(s) > 55 && s[55] != '-') {
+		return sc, errors.New("invalid traceparent length")
+	}
+	if s[2] != '-' || s[35] != '-' || s[52] != '-' {
+		return sc, errors.New("invalid traceparent format")
+	}
+	traceID, spanID, flags := s[3:35], s[36:52], s[53:55]
+	if !isLowerHex(traceID) || !isLowerHex(spanID) || !isLowerHex(flags) {
+		return sc, errors.New("traceparent must be lowercase hex")
+	}
+	_, _ = hex.Decode(sc.TraceID[:], []byte(traceID))
+	_, _ = hex.Decode(sc.SpanID[:], []byte(spanID))
+	var f [1]byte
+	_, _ = hex.Decode(f[:], []byte(flags))
+	sc.Flags = f[0]
+	if !sc.IsValid() {
+		return sc, errors.New("traceparent contains zero ID")
+	}
+	return sc, nil
+}
+
+func isLowerHex(s string) bool {
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// Generate a random trace ID.
+func newTraceID() TraceID {
+	var id TraceID
+	for !id.IsValid() {
+		_, _ = rand.Read(id[:])
+	}
+	return id
+}
+
+// Generate a random span ID.
+func newSpanID() SpanID {
+	var id SpanID
+	for !id.IsValid() {
+		_, _ = rand.Read(id[:])
+	}
+	return id
+}
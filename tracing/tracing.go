@@ -0,0 +1,178 @@
+// Package tracing implements W3C Trace Context propagation.
+//
+// https://www.w3.org/TR/trace-context/
+package tracing
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/orsinium-labs/josh"
+	"github.com/orsinium-labs/josh/headers"
+	"github.com/orsinium-labs/josh/statuses"
+)
+
+// Span is a record of handling a single request.
+type Span struct {
+	// The trace ID and the ID of this span.
+	SpanContext
+
+	// The span ID of the caller. Zero if this is the root span.
+	Parent SpanID
+
+	// The request method.
+	Method string
+
+	// The route pattern that matched the request, from [http.Request.Pattern].
+	Pattern string
+
+	// When the handler started.
+	Start time.Time
+
+	// When the handler returned.
+	End time.Time
+
+	// The response status code.
+	//
+	// Zero if the handler wrote the response directly into [http.ResponseWriter].
+	Status statuses.Status
+}
+
+// The span name: the route pattern or the request method if there is no pattern.
+func (s Span) Name() string {
+	if s.Pattern != "" {
+		return s.Pattern
+	}
+	return s.Method
+}
+
+// Exporter sends finished spans to a tracing backend.
+//
+// The Export method is called synchronously at the end of each request,
+// so it must be fast. Network exporters should buffer spans and send them
+// in a background goroutine.
+type Exporter interface {
+	Export(Span)
+}
+
+// ExporterFunc is an adapter to use an ordinary function as an [Exporter].
+type ExporterFunc func(Span)
+
+// Export implements [Exporter].
+func (f ExporterFunc) Export(s Span) {
+	f(s)
+}
+
+// Trace the request.
+//
+// The middleware reads the "traceparent" and "tracestate" headers
+// (or starts a new trace if they are missing or invalid), creates a new span,
+// and adds its [SpanContext] into the request context using [josh.WithSingleton].
+// The trace and span IDs are added as "trace-id" and "span-id"
+// into the logger set by [middlewares.WithLogger], if any.
+// When the handler returns, sampled spans are passed into the exporter.
+//
+// Use [Inject] or [Transport] to propagate the trace to outgoing requests.
+//
+// [middlewares.WithLogger]: https://pkg.go.dev/github.com/orsinium-labs/josh/middlewares#WithLogger
+func Trace(exp Exporter, h josh.Handler) josh.Handler {
+	return func(r josh.Req) josh.Resp {
+		span := Span{
+			Method:  r.Method,
+			Pattern: r.Pattern,
+			Start:   time.Now(),
+		}
+		parent, err := ParseTraceParent(r.Header.Get(string(headers.Traceparent)))
+		if err == nil {
+			span.TraceID = parent.TraceID
+			span.Parent = parent.SpanID
+			span.Flags = parent.Flags
+			span.State = parseTraceState(r.Header.Values(string(headers.Tracestate)))
+		} else {
+			span.TraceID = newTraceID()
+			span.Flags = 0x01
+		}
+		span.SpanID = newSpanID()
+
+		r = josh.Must(josh.WithSingleton(r, span.SpanContext))
+		logger, err := josh.GetSingleton[*slog.Logger](r)
+		if err == nil {
+			logger = logger.With(
+				"trace-id", span.TraceID.String(),
+				"span-id", span.SpanID.String(),
+			)
+			r = josh.ReplaceSingleton(r, logger)
+		}
+
+		resp := h(r)
+		span.End = time.Now()
+		span.Status = resp.Status
+		if span.Sampled() {
+			exp.Export(span)
+		}
+		return resp
+	}
+}
+
+// Combine multiple "tracestate" headers into one, dropping it if it's too long.
+func parseTraceState(values []string) string {
+	state := strings.Join(values, ",")
+	if len(state) > maxTraceState {
+		return ""
+	}
+	return state
+}
+
+// Get the [SpanContext] set by [Trace] from the context.
+func FromContext(ctx context.Context) (SpanContext, bool) {
+	sc, err := josh.CGetSingleton[SpanContext](ctx)
+	return sc, err == nil
+}
+
+// Set the "traceparent" and "tracestate" headers for an outgoing request.
+//
+// The span context is taken from the given context. If there is none, nothing is set.
+func Inject(ctx context.Context, h http.Header) {
+	sc, found := FromContext(ctx)
+	if !found {
+		return
+	}
+	h.Set(string(headers.Traceparent), sc.TraceParent())
+	if sc.State != "" {
+		h.Set(string(headers.Tracestate), sc.State)
+	} else {
+		h.Del(string(headers.Tracestate))
+	}
+}
+
+// Wrap an [http.RoundTripper] to propagate the trace from the request context.
+//
+// If base is nil, [http.DefaultTransport] is used.
+//
+//	client := &http.Client{Transport: tracing.Transport(nil)}
+//	req, _ := http.NewRequestWithContext(r.Context(), "GET", url, nil)
+//	resp, err := client.Do(req)
+func Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return transport{base: base}
+}
+
+type transport struct {
+	base http.RoundTripper
+}
+
+// RoundTrip implements [http.RoundTripper].
+func (t transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if _, found := FromContext(req.Context()); !found {
+		return t.base.RoundTrip(req)
+	}
+	// RoundTripper must not modify the request.
+	req = req.Clone(req.Context())
+	Inject(req.Context(), req.Header)
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,125 @@
+package tracing_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/orsinium-labs/josh"
+	"github.com/orsinium-labs/josh/middlewares"
+	"github.com/orsinium-labs/josh/tracing"
+)
+
+func eq[T comparable](a, b T) {
+	if a != b {
+		panic(fmt.Sprintf("%v != %v", a, b))
+	}
+}
+
+const parent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestParseTraceParent(t *testing.T) {
+	sc, err := tracing.ParseTraceParent(parent)
+	eq(err, nil)
+	eq(sc.TraceID.String(), "4bf92f3577b34da6a3ce929d0e0e4736")
+	eq(sc.SpanID.String(), "00f067aa0ba902b7")
+	eq(sc.Sampled(), true)
+	eq(sc.TraceParent(), parent)
+
+	invalid := []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00_4bf92f3577b34da6a3ce929d0e0e4736_00f067aa0ba902b7_01",
+	}
+	for _, s := range invalid {
+		_, err := tracing.ParseTraceParent(s)
+		if err == nil {
+			t.Fatalf("expected error for %q", s)
+		}
+	}
+
+	// Future versions may have more fields.
+	_, err = tracing.ParseTraceParent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra")
+	eq(err, nil)
+}
+
+func TestTrace(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	var spans []tracing.Span
+	exp := tracing.ExporterFunc(func(s tracing.Span) {
+		spans = append(spans, s)
+	})
+	var outgoing http.Header
+	hf := func(r josh.Req) josh.Resp {
+		josh.Must(josh.GetSingleton[*slog.Logger](r)).Info("hello")
+		outgoing = http.Header{}
+		tracing.Inject(r.Context(), outgoing)
+		return josh.Created("hi")
+	}
+	h := josh.Wrap(middlewares.WithLogger(logger, tracing.Trace(exp, hf)))
+	req := httptest.NewRequest("POST", "http://example.com/foo", nil)
+	req.Header.Set("traceparent", parent)
+	req.Header.Set("tracestate", "congo=t61rcWkgMzE")
+	w := httptest.NewRecorder()
+	h(w, req)
+	eq(w.Code, 201)
+
+	eq(len(spans), 1)
+	span := spans[0]
+	eq(span.TraceID.String(), "4bf92f3577b34da6a3ce929d0e0e4736")
+	eq(span.Parent.String(), "00f067aa0ba902b7")
+	eq(span.SpanID.IsValid(), true)
+	eq(span.Status, 201)
+	eq(span.Method, "POST")
+	eq(span.End.Before(span.Start), false)
+
+	expParent := "00-4bf92f3577b34da6a3ce929d0e0e4736-" + span.SpanID.String() + "-01"
+	eq(outgoing.Get("traceparent"), expParent)
+	eq(outgoing.Get("tracestate"), "congo=t61rcWkgMzE")
+	eq(strings.Contains(buf.String(), "trace-id=4bf92f3577b34da6a3ce929d0e0e4736"), true)
+}
+
+func TestTrace_NewTrace(t *testing.T) {
+	var spans []tracing.Span
+	exp := tracing.ExporterFunc(func(s tracing.Span) {
+		spans = append(spans, s)
+	})
+	hf := func(r josh.Req) josh.Resp {
+		return josh.NoContent()
+	}
+	h := josh.Wrap(tracing.Trace(exp, hf))
+	req := httptest.NewRequest("GET", "http://example.com/foo", nil)
+	req.Header.Set("traceparent", "garbage")
+	w := httptest.NewRecorder()
+	h(w, req)
+	eq(len(spans), 1)
+	eq(spans[0].TraceID.IsValid(), true)
+	eq(spans[0].Parent.IsValid(), false)
+}
+
+func TestTransport(t *testing.T) {
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("traceparent")
+	}))
+	defer srv.Close()
+
+	sc, _ := tracing.ParseTraceParent(parent)
+	ctx := josh.Must(josh.CWithSingleton(context.Background(), sc))
+	req := josh.Must(http.NewRequestWithContext(ctx, "GET", srv.URL, nil))
+	client := &http.Client{Transport: tracing.Transport(nil)}
+	resp := josh.Must(client.Do(req))
+	_ = resp.Body.Close()
+	eq(got, parent)
+	eq(req.Header.Get("traceparent"), "")
+}
@@ -0,0 +1,407 @@
+package ws
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/orsinium-labs/josh"
+)
+
+// MessageType is the type of a data message.
+type MessageType byte
+
+const (
+	TextMessage   MessageType = 1
+	BinaryMessage MessageType = 2
+)
+
+// Frame opcodes.
+//
+// https://datatracker.ietf.org/doc/html/rfc6455#section-5.2
+const (
+	opContinuation = 0
+	opText         = 1
+	opBinary       = 2
+	opClose        = 8
+	opPing         = 9
+	opPong         = 10
+)
+
+// CloseCode is the status code of a closed connection.
+//
+// https://datatracker.ietf.org/doc/html/rfc6455#section-7.4.1
+type CloseCode uint16
+
+const (
+	CloseNormal          CloseCode = 1000
+	CloseGoingAway       CloseCode = 1001
+	CloseProtocolError   CloseCode = 1002
+	CloseUnsupportedData CloseCode = 1003
+	// Reported when the close frame has no status code. Never sent.
+	CloseNoStatus CloseCode = 1005
+	// Reported when the connection is closed without a close frame. Never sent.
+	CloseAbnormal        CloseCode = 1006
+	CloseInvalidPayload  CloseCode = 1007
+	ClosePolicyViolation CloseCode = 1008
+	CloseMessageTooBig   CloseCode = 1009
+	CloseInternalError   CloseCode = 1011
+)
+
+// ErrClosed is returned when writing into a closed connection.
+var ErrClosed = errors.New("websocket connection is closed")
+
+// CloseError is returned by [Conn.Read] when the connection is closed
+// by the client or because of a protocol violation.
+type CloseError struct {
+	Code   CloseCode
+	Reason string
+}
+
+func (err *CloseError) Error() string {
+	if err.Reason == "" {
+		return fmt.Sprintf("websocket closed with code %d", err.Code)
+	}
+	return fmt.Sprintf("websocket closed with code %d: %s", err.Code, err.Reason)
+}
+
+// The bytes that the deflate stream of each message ends with.
+// They are stripped by the sender and added back by the receiver.
+var deflateTail = []byte{0x00, 0x00, 0xff, 0xff}
+
+// Conn is a WebSocket connection.
+//
+// Created by [Upgrade]. Reads must happen in one goroutine,
+// writes are safe for concurrent use.
+type Conn struct {
+	// The selected subprotocol. Empty if none.
+	Subprotocol string
+
+	req      josh.Req
+	conn     net.Conn
+	reader   *bufio.Reader
+	compress bool
+	maxSize  int64
+	timeout  time.Duration
+	// How long writing a frame can take. Zero or negative means no limit.
+	writeTimeout time.Duration
+
+	// Held while writing a frame.
+	wmu sync.Mutex
+	// Set when the close frame is sent. Protected by wmu.
+	closeSent bool
+	closeOnce sync.Once
+	stop      chan struct{}
+}
+
+func newConn(r josh.Req, conn net.Conn, reader *bufio.Reader, protocol string, compress bool, opts Options) *Conn {
+	c := &Conn{
+		Subprotocol:  protocol,
+		req:          r,
+		conn:         conn,
+		reader:       reader,
+		compress:     compress,
+		maxSize:      opts.MaxMessageSize,
+		writeTimeout: opts.WriteTimeout,
+		stop:         make(chan struct{}),
+	}
+	if c.maxSize == 0 {
+		c.maxSize = 1024 * 1024
+	}
+	interval := opts.PingInterval
+	if interval == 0 {
+		interval = 30 * time.Second
+	}
+	if interval > 0 {
+		c.timeout = 2 * interval
+		_ = conn.SetReadDeadline(time.Now().Add(c.timeout))
+		go c.ping(interval)
+	}
+	return c
+}
+
+// Send pings until the connection is closed.
+func (c *Conn) ping(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			err := c.writeFrame(opPing, nil, false)
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+// The request that was upgraded to this connection.
+func (c *Conn) Request() josh.Req {
+	return c.req
+}
+
+// Read the next data message.
+//
+// Pings are answered automatically. If the client closes the connection
+// or violates the protocol, [CloseError] is returned.
+func (c *Conn) Read() (MessageType, []byte, error) {
+	var msgType MessageType
+	var buf []byte
+	compressed := false
+	started := false
+	for {
+		f, err := c.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+		if c.timeout > 0 {
+			_ = c.conn.SetReadDeadline(time.Now().Add(c.timeout))
+		}
+		if f.rsv1 && (!c.compress || f.op != opText && f.op != opBinary) {
+			return 0, nil, c.fail(CloseProtocolError, "unexpected RSV1 bit")
+		}
+		switch f.op {
+		case opPing:
+			err = c.writeFrame(opPong, f.payload, false)
+			if err != nil {
+				return 0, nil, err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			return 0, nil, c.handleClose(f.payload)
+		case opContinuation:
+			if !started {
+				return 0, nil, c.fail(CloseProtocolError, "unexpected continuation frame")
+			}
+		case opText, opBinary:
+			if started {
+				return 0, nil, c.fail(CloseProtocolError, "expected continuation frame")
+			}
+			started = true
+			msgType = MessageType(f.op)
+			compressed = f.rsv1
+		default:
+			return 0, nil, c.fail(CloseProtocolError, "unknown opcode")
+		}
+		if int64(len(buf)+len(f.payload)) > c.maxSize {
+			return 0, nil, c.fail(CloseMessageTooBig, "")
+		}
+		buf = append(buf, f.payload...)
+		if f.fin {
+			break
+		}
+	}
+	if compressed {
+		var err error
+		buf, err = c.inflate(buf)
+		if err != nil {
+			return 0, nil, err
+		}
+	}
+	if msgType == TextMessage && !utf8.Valid(buf) {
+		return 0, nil, c.fail(CloseInvalidPayload, "invalid UTF-8")
+	}
+	return msgType, buf, nil
+}
+
+// Send a data message.
+func (c *Conn) Write(t MessageType, data []byte) error {
+	if !c.compress {
+		return c.writeFrame(byte(t), data, false)
+	}
+	var buf bytes.Buffer
+	fw, _ := flate.NewWriter(&buf, flate.DefaultCompression)
+	_, _ = fw.Write(data)
+	_ = fw.Flush()
+	compressed := bytes.TrimSuffix(buf.Bytes(), deflateTail)
+	return c.writeFrame(byte(t), compressed, true)
+}
+
+// Close the connection with the given code and reason.
+//
+// The close frame is sent without waiting for the client to respond.
+// Safe to call multiple times.
+func (c *Conn) Close(code CloseCode, reason string) error {
+	var err error
+	c.closeOnce.Do(func() {
+		close(c.stop)
+		payload := make([]byte, 2, 2+len(reason))
+		binary.BigEndian.PutUint16(payload, uint16(code))
+		payload = append(payload, reason...)
+		if len(payload) > 125 {
+			payload = payload[:125]
+		}
+		err = c.writeFrame(opClose, payload, false)
+		closeErr := c.conn.Close()
+		if err == nil || errors.Is(err, ErrClosed) {
+			err = closeErr
+		}
+	})
+	return err
+}
+
+// Close the connection because of a protocol violation.
+func (c *Conn) fail(code CloseCode, reason string) error {
+	_ = c.Close(code, reason)
+	return &CloseError{Code: code, Reason: reason}
+}
+
+// Respond to the close frame sent by the client.
+func (c *Conn) handleClose(payload []byte) error {
+	closeErr := &CloseError{Code: CloseNoStatus}
+	switch {
+	case len(payload) == 1:
+		return c.fail(CloseProtocolError, "invalid close frame")
+	case len(payload) >= 2:
+		closeErr.Code = CloseCode(binary.BigEndian.Uint16(payload))
+		closeErr.Reason = string(payload[2:])
+		if !validCloseCode(closeErr.Code) {
+			return c.fail(CloseProtocolError, "invalid close code")
+		}
+		if !utf8.ValidString(closeErr.Reason) {
+			return c.fail(CloseInvalidPayload, "invalid UTF-8")
+		}
+	}
+	code := closeErr.Code
+	if code == CloseNoStatus {
+		code = CloseNormal
+	}
+	_ = c.Close(code, "")
+	return closeErr
+}
+
+// Check if the close code can be sent over the wire.
+func validCloseCode(code CloseCode) bool {
+	switch {
+	case code >= 3000 && code <= 4999:
+		return true
+	case code >= 1000 && code <= 1011:
+		return code != 1004 && code != CloseNoStatus && code != CloseAbnormal
+	}
+	return false
+}
+
+type frame struct {
+	fin     bool
+	rsv1    bool
+	op      byte
+	payload []byte
+}
+
+// Read a single frame from the client.
+func (c *Conn) readFrame() (frame, error) {
+	var f frame
+	var head [2]byte
+	_, err := io.ReadFull(c.reader, head[:])
+	if err != nil {
+		return f, c.readError(err)
+	}
+	f.fin = head[0]&0x80 != 0
+	f.rsv1 = head[0]&0x40 != 0
+	f.op = head[0] & 0x0f
+	if head[0]&0x30 != 0 {
+		return f, c.fail(CloseProtocolError, "unexpected RSV bits")
+	}
+	if head[1]&0x80 == 0 {
+		return f, c.fail(CloseProtocolError, "client frames must be masked")
+	}
+	size := uint64(head[1] & 0x7f)
+	switch size {
+	case 126:
+		var ext [2]byte
+		_, err = io.ReadFull(c.reader, ext[:])
+		size = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		_, err = io.ReadFull(c.reader, ext[:])
+		size = binary.BigEndian.Uint64(ext[:])
+	}
+	if err != nil {
+		return f, c.readError(err)
+	}
+	if f.op >= opClose && (size > 125 || !f.fin) {
+		return f, c.fail(CloseProtocolError, "invalid control frame")
+	}
+	if size > uint64(c.maxSize) {
+		return f, c.fail(CloseMessageTooBig, "")
+	}
+	var mask [4]byte
+	_, err = io.ReadFull(c.reader, mask[:])
+	if err != nil {
+		return f, c.readError(err)
+	}
+	f.payload = make([]byte, size)
+	_, err = io.ReadFull(c.reader, f.payload)
+	if err != nil {
+		return f, c.readError(err)
+	}
+	for i := range f.payload {
+		f.payload[i] ^= mask[i%4]
+	}
+	return f, nil
+}
+
+// Convert the network error into [CloseError].
+func (c *Conn) readError(err error) error {
+	_ = c.Close(CloseGoingAway, "")
+	return &CloseError{Code: CloseAbnormal, Reason: err.Error()}
+}
+
+// Write a single unfragmented frame.
+func (c *Conn) writeFrame(op byte, payload []byte, compressed bool) error {
+	head := make([]byte, 2, 10+len(payload))
+	head[0] = 0x80 | op
+	if compressed {
+		head[0] |= 0x40
+	}
+	switch size := len(payload); {
+	case size <= 125:
+		head[1] = byte(size)
+	case size <= 0xffff:
+		head[1] = 126
+		head = binary.BigEndian.AppendUint16(head, uint16(size))
+	default:
+		head[1] = 127
+		head = binary.BigEndian.AppendUint64(head, uint64(size))
+	}
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if c.closeSent {
+		return ErrClosed
+	}
+	if op == opClose {
+		c.closeSent = true
+	}
+	if c.writeTimeout > 0 {
+		_ = c.conn.SetWriteDeadline(time.Now().Add(c.writeTimeout))
+	}
+	_, err := c.conn.Write(append(head, payload...))
+	return err
+}
+
+// Decompress the message payload.
+func (c *Conn) inflate(data []byte) ([]byte, error) {
+	r := flate.NewReader(io.MultiReader(bytes.NewReader(data), bytes.NewReader(deflateTail)))
+	defer r.Close()
+	result, err := io.ReadAll(io.LimitReader(r, c.maxSize+1))
+	// The stream has no final block, so the reader reports unexpected EOF at the end.
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, c.fail(CloseInvalidPayload, "invalid compressed data")
+	}
+	if int64(len(result)) > c.maxSize {
+		return nil, c.fail(CloseMessageTooBig, "")
+	}
+	return result, nil
+}
@@ -0,0 +1,273 @@
+// Package ws is a WebSocket server implementation (RFC 6455) for josh handlers.
+//
+// The connection is taken over from the [http.ResponseWriter] added into
+// the request by [josh.Wrap], so it works with all josh middlewares,
+// including [middlewares.Auth] with the "Sec-WebSocket-Protocol" workaround.
+//
+//	h := ws.Handler(ws.Options{}, func(r josh.Req, conn *ws.Conn) error {
+//		return conn.Serve(r.Context(), &dispatcher)
+//	})
+//	h = middlewares.Auth(validator, h)
+//
+// [middlewares.Auth]: https://pkg.go.dev/github.com/orsinium-labs/josh/middlewares#Auth
+package ws
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/orsinium-labs/josh"
+	"github.com/orsinium-labs/josh/headers"
+	"github.com/orsinium-labs/josh/statuses"
+)
+
+// The GUID used to compute "Sec-WebSocket-Accept" from "Sec-WebSocket-Key".
+const keyGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// The protocol that [middlewares.Auth] expects before the token.
+//
+// [middlewares.Auth]: https://pkg.go.dev/github.com/orsinium-labs/josh/middlewares#Auth
+const authProtocol = "Authorization"
+
+// Options for [Upgrade].
+type Options struct {
+	// Check if the request with the given "Origin" header is allowed.
+	//
+	// By default, only requests without "Origin" or with the origin
+	// matching the request host are allowed. Browsers don't apply CORS
+	// to WebSockets, so without this check any website could connect
+	// on behalf of the user.
+	CheckOrigin func(r josh.Req) bool
+
+	// The subprotocols supported by the server, in the order of preference.
+	//
+	// If the client requested the "Authorization" protocol (see [middlewares.Auth])
+	// and none of the supported ones, "Authorization" is selected because
+	// browsers close the connection if the server doesn't select any protocol.
+	//
+	// [middlewares.Auth]: https://pkg.go.dev/github.com/orsinium-labs/josh/middlewares#Auth
+	Subprotocols []string
+
+	// Compress messages using the "permessage-deflate" extension
+	// if the client supports it. Context takeover is disabled,
+	// so each message is compressed independently.
+	//
+	// https://datatracker.ietf.org/doc/html/rfc7692
+	Compression bool
+
+	// The maximum size of a received message. Defaults to 1 MB.
+	MaxMessageSize int64
+
+	// How often to ping the client. Defaults to 30 seconds. Negative disables pings.
+	//
+	// If nothing is received from the client for two intervals,
+	// the connection is considered dead and reading from it fails.
+	PingInterval time.Duration
+
+	// How long writing a frame can take. Defaults to 10 seconds. Negative disables the timeout.
+	//
+	// If the client doesn't read the messages, writes fail after the timeout
+	// instead of blocking all writers of the connection.
+	WriteTimeout time.Duration
+}
+
+// HandshakeError is returned by [Upgrade] if the request is not a valid WebSocket handshake.
+type HandshakeError struct {
+	Status statuses.Status
+	Detail string
+}
+
+func (err *HandshakeError) Error() string {
+	return "websocket handshake: " + err.Detail
+}
+
+// Upgrade the HTTP connection to a WebSocket connection.
+//
+// After a successful upgrade, the handler must not use the [http.ResponseWriter]
+// and must return [josh.NoResponse]. See [Handler] for a wrapper doing all of that.
+//
+// The headers already set on the [http.ResponseWriter] by middlewares,
+// like "Set-Cookie" or "X-Request-ID", are sent in the handshake response.
+func Upgrade(r josh.Req, opts Options) (*Conn, error) {
+	if r.Method != http.MethodGet {
+		return nil, &HandshakeError{statuses.MethodNotAllowed, "method must be GET"}
+	}
+	if r.ProtoMajor != 1 {
+		return nil, &HandshakeError{statuses.HTTPVersionNotSupported, "only HTTP/1.1 is supported"}
+	}
+	if !headerContains(r.Header, headers.Connection, "upgrade") || !headerContains(r.Header, headers.Upgrade, "websocket") {
+		return nil, &HandshakeError{statuses.UpgradeRequired, "not a WebSocket upgrade request"}
+	}
+	if r.Header.Get(string(headers.SecWebSocketVersion)) != "13" {
+		return nil, &HandshakeError{statuses.UpgradeRequired, "unsupported Sec-WebSocket-Version"}
+	}
+	key := r.Header.Get(string(headers.SecWebSocketKey))
+	decoded, err := base64.StdEncoding.DecodeString(key)
+	if err != nil || len(decoded) != 16 {
+		return nil, &HandshakeError{statuses.BadRequest, "invalid Sec-WebSocket-Key"}
+	}
+	checkOrigin := opts.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = sameOrigin
+	}
+	if !checkOrigin(r) {
+		return nil, &HandshakeError{statuses.Forbidden, "origin not allowed"}
+	}
+
+	w := josh.Must(josh.GetSingleton[http.ResponseWriter](r))
+	netConn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		return nil, &HandshakeError{statuses.InternalServerError, fmt.Sprintf("cannot take over the connection: %v", err)}
+	}
+	// Remove the deadlines set by the server for HTTP requests.
+	_ = netConn.SetDeadline(time.Time{})
+	writeTimeout := opts.WriteTimeout
+	if writeTimeout == 0 {
+		writeTimeout = 10 * time.Second
+	}
+	if writeTimeout > 0 {
+		_ = netConn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	}
+
+	protocol := selectProtocol(r, opts.Subprotocols)
+	compress := opts.Compression && acceptsDeflate(r)
+	hash := sha1.Sum([]byte(key + keyGUID))
+	header := w.Header().Clone()
+	// The body headers make no sense for 101.
+	header.Del(string(headers.ContentType))
+	header.Del(string(headers.ContentLength))
+	header.Set(string(headers.Upgrade), "websocket")
+	header.Set(string(headers.Connection), "Upgrade")
+	header.Set(string(headers.SecWebSocketAccept), base64.StdEncoding.EncodeToString(hash[:]))
+	header.Del(string(headers.SecWebSocketProtocol))
+	if protocol != "" {
+		header.Set(string(headers.SecWebSocketProtocol), protocol)
+	}
+	header.Del(string(headers.SecWebSocketExtensions))
+	if compress {
+		header.Set(string(headers.SecWebSocketExtensions), "permessage-deflate; server_no_context_takeover; client_no_context_takeover")
+	}
+	var resp strings.Builder
+	resp.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+	_ = header.Write(&resp)
+	resp.WriteString("\r\n")
+	_, err = rw.WriteString(resp.String())
+	if err == nil {
+		err = rw.Flush()
+	}
+	if err != nil {
+		_ = netConn.Close()
+		return nil, err
+	}
+	opts.WriteTimeout = writeTimeout
+	return newConn(r, netConn, rw.Reader, protocol, compress, opts), nil
+}
+
+// Upgrade the connection and call the function with it.
+//
+// Handshake errors are returned as JSON:API error responses.
+// When the function returns, the connection is closed with
+// [CloseNormal] if the error is nil or [CloseInternalError] otherwise.
+func Handler(opts Options, f func(josh.Req, *Conn) error) josh.Handler {
+	return func(r josh.Req) josh.Resp {
+		conn, err := Upgrade(r, opts)
+		if err != nil {
+			var hsErr *HandshakeError
+			if !errors.As(err, &hsErr) {
+				return josh.NoResponse()
+			}
+			if hsErr.Status == statuses.UpgradeRequired {
+				josh.SetHeader(r, headers.SecWebSocketVersion, "13")
+			}
+			return josh.Resp{
+				Status: hsErr.Status,
+				Errors: []josh.Error{{
+					Title:  "WebSocket handshake failed",
+					Detail: hsErr.Detail,
+				}},
+			}
+		}
+		err = f(r, conn)
+		if err != nil {
+			_ = conn.Close(CloseInternalError, "")
+		} else {
+			_ = conn.Close(CloseNormal, "")
+		}
+		return josh.NoResponse()
+	}
+}
+
+// Check if any of the comma-separated header values is equal to the token.
+func headerContains(h http.Header, name headers.Header, token string) bool {
+	for _, value := range h.Values(string(name)) {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// The default origin check of [Options.CheckOrigin].
+func sameOrigin(r josh.Req) bool {
+	origin := r.Header.Get(string(headers.Origin))
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+// Select the subprotocol for the response.
+func selectProtocol(r josh.Req, supported []string) string {
+	var requested []string
+	for _, value := range r.Header.Values(string(headers.SecWebSocketProtocol)) {
+		for _, part := range strings.Split(value, ",") {
+			requested = append(requested, strings.TrimSpace(part))
+		}
+	}
+	for _, protocol := range supported {
+		if slices.Contains(requested, protocol) {
+			return protocol
+		}
+	}
+	if slices.Contains(requested, authProtocol) {
+		return authProtocol
+	}
+	return ""
+}
+
+// Check if the client offers the "permessage-deflate" extension with parameters we support.
+func acceptsDeflate(r josh.Req) bool {
+	for _, value := range r.Header.Values(string(headers.SecWebSocketExtensions)) {
+		for _, offer := range strings.Split(value, ",") {
+			params := strings.Split(offer, ";")
+			if strings.TrimSpace(params[0]) != "permessage-deflate" {
+				continue
+			}
+			ok := true
+			for _, param := range params[1:] {
+				name, val, _ := strings.Cut(strings.TrimSpace(param), "=")
+				// The compress/flate package always uses the 32 KB window.
+				if name == "server_max_window_bits" && strings.Trim(val, `"`) != "15" {
+					ok = false
+				}
+			}
+			if ok {
+				return true
+			}
+		}
+	}
+	return false
+}
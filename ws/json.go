@@ -0,0 +1,74 @@
+package ws
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/orsinium-labs/josh"
+)
+
+// Send a JSON:API document as a text message.
+//
+// Only the document is sent, the status code is ignored.
+// Empty responses (like [josh.NoResponse]) are not sent.
+func (c *Conn) Send(resp josh.Resp) error {
+	if resp.Data == nil && resp.Errors == nil && resp.Meta == nil {
+		return nil
+	}
+	raw, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	return c.Write(TextMessage, raw)
+}
+
+// Receive a JSON:API document with a resource object of the given type.
+//
+// See [josh.Read].
+func Receive[T any](c *Conn, t string) (josh.Data[T], error) {
+	msgType, raw, err := c.Read()
+	if err != nil {
+		return josh.Data[T]{}, err
+	}
+	if msgType != TextMessage {
+		return josh.Data[T]{}, errors.New("expected a text message")
+	}
+	return josh.Read[T](t, bytes.NewReader(raw))
+}
+
+// Handle incoming messages using the dispatcher until the connection is closed.
+//
+// Each text message must be a JSON:API document which resource object type
+// is registered in the dispatcher (see [josh.Register]). The responses
+// (including errors) are sent back as text messages.
+//
+// Returns nil if the client closes the connection normally.
+func (c *Conn) Serve(ctx context.Context, d *josh.Dispatcher) error {
+	for {
+		msgType, raw, err := c.Read()
+		if err != nil {
+			var closeErr *CloseError
+			if errors.As(err, &closeErr) {
+				switch closeErr.Code {
+				case CloseNormal, CloseGoingAway, CloseNoStatus:
+					return nil
+				}
+			}
+			return err
+		}
+		var resp josh.Resp
+		if msgType != TextMessage {
+			resp = josh.BadRequest(josh.Error{
+				Title: "Binary messages are not supported",
+			})
+		} else {
+			resp = d.Read(ctx, bytes.NewReader(raw))
+		}
+		err = c.Send(resp)
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package ws_test
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/orsinium-labs/josh"
+	"github.com/orsinium-labs/josh/ws"
+)
+
+func eq[T comparable](a, b T) {
+	if a != b {
+		panic(fmt.Sprintf("%v != %v", a, b))
+	}
+}
+
+// A minimal WebSocket client.
+type client struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	resp   *http.Response
+}
+
+func dial(t *testing.T, url string, hdrs map[string]string) *client {
+	t.Helper()
+	addr := strings.TrimPrefix(url, "http://")
+	conn := josh.Must(net.Dial("tcp", addr))
+	req := "GET / HTTP/1.1\r\nHost: " + addr + "\r\n" +
+		"Upgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n"
+	for k, v := range hdrs {
+		req += k + ": " + v + "\r\n"
+	}
+	_ = josh.Must(conn.Write([]byte(req + "\r\n")))
+	reader := bufio.NewReader(conn)
+	resp := josh.Must(http.ReadResponse(reader, nil))
+	return &client{conn: conn, reader: reader, resp: resp}
+}
+
+func (c *client) write(op byte, fin bool, rsv1 bool, payload []byte) {
+	head := []byte{op, 0x80}
+	if fin {
+		head[0] |= 0x80
+	}
+	if rsv1 {
+		head[0] |= 0x40
+	}
+	switch {
+	case len(payload) <= 125:
+		head[1] |= byte(len(payload))
+	default:
+		head[1] |= 126
+		head = binary.BigEndian.AppendUint16(head, uint16(len(payload)))
+	}
+	mask := []byte{1, 2, 3, 4}
+	masked := make([]byte, len(payload))
+	for i := range payload {
+		masked[i] = payload[i] ^ mask[i%4]
+	}
+	_ = josh.Must(c.conn.Write(append(append(head, mask...), masked...)))
+}
+
+func (c *client) read() (byte, bool, []byte) {
+	var head [2]byte
+	_ = josh.Must(io.ReadFull(c.reader, head[:]))
+	size := int(head[1] & 0x7f)
+	if size == 126 {
+		var ext [2]byte
+		_ = josh.Must(io.ReadFull(c.reader, ext[:]))
+		size = int(binary.BigEndian.Uint16(ext[:]))
+	}
+	payload := make([]byte, size)
+	_ = josh.Must(io.ReadFull(c.reader, payload))
+	return head[0] & 0x0f, head[0]&0x40 != 0, payload
+}
+
+func echo(r josh.Req, conn *ws.Conn) error {
+	for {
+		t, msg, err := conn.Read()
+		if err != nil {
+			return nil
+		}
+		err = conn.Write(t, msg)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func TestHandshake(t *testing.T) {
+	srv := httptest.NewServer(josh.Wrap(ws.Handler(ws.Options{}, echo)))
+	defer srv.Close()
+
+	c := dial(t, srv.URL, map[string]string{"Sec-WebSocket-Protocol": "Authorization, secret"})
+	eq(c.resp.StatusCode, 101)
+	// The example from RFC 6455.
+	eq(c.resp.Header.Get("Sec-WebSocket-Accept"), "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=")
+	eq(c.resp.Header.Get("Sec-WebSocket-Protocol"), "Authorization")
+	eq(c.resp.Header.Get("Sec-WebSocket-Extensions"), "")
+
+	c = dial(t, srv.URL, map[string]string{"Origin": "https://evil.com"})
+	eq(c.resp.StatusCode, 403)
+
+	resp := josh.Must(http.Get(srv.URL))
+	eq(resp.StatusCode, 426)
+	eq(resp.Header.Get("Sec-WebSocket-Version"), "13")
+}
+
+func TestHandshake_Headers(t *testing.T) {
+	h := ws.Handler(ws.Options{}, echo)
+	srv := httptest.NewServer(josh.Wrap(func(r josh.Req) josh.Resp {
+		josh.SetHeader(r, "X-Request-ID", "req-1")
+		josh.SetHeader(r, "Set-Cookie", "csrf_token=abc")
+		josh.SetHeader(r, "Upgrade", "h2c")
+		return h(r)
+	}))
+	defer srv.Close()
+
+	c := dial(t, srv.URL, nil)
+	eq(c.resp.StatusCode, 101)
+	eq(c.resp.Header.Get("X-Request-ID"), "req-1")
+	eq(c.resp.Header.Get("Set-Cookie"), "csrf_token=abc")
+	eq(c.resp.Header.Get("Upgrade"), "websocket")
+}
+
+func TestConn_WriteTimeout(t *testing.T) {
+	errs := make(chan error, 1)
+	srv := httptest.NewServer(josh.Wrap(ws.Handler(ws.Options{WriteTimeout: 20 * time.Millisecond}, func(r josh.Req, conn *ws.Conn) error {
+		// The client never reads, so the buffers fill up and the write times out.
+		data := make([]byte, 1024*1024)
+		for {
+			err := conn.Write(ws.BinaryMessage, data)
+			if err != nil {
+				errs <- err
+				return err
+			}
+		}
+	})))
+	defer srv.Close()
+	c := dial(t, srv.URL, nil)
+	defer c.conn.Close()
+	err := <-errs
+	eq(errors.Is(err, os.ErrDeadlineExceeded), true)
+}
+
+func TestConn_Echo(t *testing.T) {
+	srv := httptest.NewServer(josh.Wrap(ws.Handler(ws.Options{}, echo)))
+	defer srv.Close()
+	c := dial(t, srv.URL, nil)
+
+	c.write(1, true, false, []byte("hello"))
+	op, _, payload := c.read()
+	eq(op, 1)
+	eq(string(payload), "hello")
+
+	// Fragmented message with a ping in the middle.
+	c.write(2, false, false, []byte("frag"))
+	c.write(9, true, false, []byte("ping"))
+	c.write(0, true, false, bytes.Repeat([]byte("x"), 200))
+	op, _, payload = c.read()
+	eq(op, 10)
+	eq(string(payload), "ping")
+	op, _, payload = c.read()
+	eq(op, 2)
+	eq(len(payload), 204)
+
+	// Invalid UTF-8 in a text message.
+	c.write(1, true, false, []byte{0xff})
+	op, _, payload = c.read()
+	eq(op, 8)
+	eq(binary.BigEndian.Uint16(payload), 1007)
+}
+
+func TestConn_Close(t *testing.T) {
+	srv := httptest.NewServer(josh.Wrap(ws.Handler(ws.Options{}, echo)))
+	defer srv.Close()
+	c := dial(t, srv.URL, nil)
+	c.write(8, true, false, []byte{0x03, 0xe8, 'b', 'y', 'e'})
+	op, _, payload := c.read()
+	eq(op, 8)
+	eq(binary.BigEndian.Uint16(payload), 1000)
+	_, err := c.reader.ReadByte()
+	eq(err, io.EOF)
+}
+
+func TestConn_Compression(t *testing.T) {
+	srv := httptest.NewServer(josh.Wrap(ws.Handler(ws.Options{Compression: true}, echo)))
+	defer srv.Close()
+	c := dial(t, srv.URL, map[string]string{
+		"Sec-WebSocket-Extensions": "permessage-deflate; client_max_window_bits",
+	})
+	eq(strings.HasPrefix(c.resp.Header.Get("Sec-WebSocket-Extensions"), "permessage-deflate"), true)
+
+	var buf bytes.Buffer
+	fw := josh.Must(flate.NewWriter(&buf, flate.BestCompression))
+	text := strings.Repeat("all work and no play ", 20)
+	_ = josh.Must(fw.Write([]byte(text)))
+	_ = fw.Flush()
+	c.write(1, true, true, bytes.TrimSuffix(buf.Bytes(), []byte{0, 0, 0xff, 0xff}))
+
+	op, rsv1, payload := c.read()
+	eq(op, 1)
+	eq(rsv1, true)
+	eq(len(payload) < len(text), true)
+	fr := flate.NewReader(io.MultiReader(bytes.NewReader(payload), bytes.NewReader([]byte{0, 0, 0xff, 0xff})))
+	decoded, _ := io.ReadAll(fr)
+	eq(string(decoded), text)
+}
+
+type Greeting struct {
+	Name string `json:"name"`
+}
+
+func TestConn_Serve(t *testing.T) {
+	d := josh.NewDispatcher()
+	josh.Register(&d, "greeting", func(ctx context.Context, g Greeting) josh.Resp {
+		return josh.Ok("hello " + g.Name)
+	})
+	srv := httptest.NewServer(josh.Wrap(ws.Handler(ws.Options{}, func(r josh.Req, conn *ws.Conn) error {
+		return conn.Serve(r.Context(), &d)
+	})))
+	defer srv.Close()
+	c := dial(t, srv.URL, nil)
+
+	c.write(1, true, false, []byte(`{"data": {"type": "greeting", "attributes": {"name": "Frodo"}}}`))
+	_, _, payload := c.read()
+	eq(string(payload), `{"data":"hello Frodo"}`)
+
+	c.write(1, true, false, []byte(`{"data": {"type": "farewell"}}`))
+	_, _, payload = c.read()
+	eq(string(payload), `{"errors":[{"title":"Unsupported request type"}]}`)
+
+	c.write(8, true, false, []byte{0x03, 0xe8})
+	op, _, _ := c.read()
+	eq(op, 8)
+}